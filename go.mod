@@ -3,15 +3,19 @@ module github.com/jcchavezs/nuro
 go 1.23.5
 
 require (
+	github.com/fxamacker/cbor/v2 v2.7.0
 	github.com/jdx/go-netrc v1.0.0
 	github.com/jedib0t/go-pretty v4.3.0+incompatible
+	github.com/klauspost/compress v1.17.11
 	github.com/spf13/cobra v1.9.1
+	github.com/spf13/pflag v1.0.6
 	github.com/stretchr/testify v1.10.0
 	github.com/thediveo/enumflag v0.10.1
 	github.com/thediveo/enumflag/v2 v2.0.7
 	github.com/thessem/zap-prettyconsole v0.5.2
 	github.com/yuseferi/zax/v2 v2.3.3
 	go.uber.org/zap v1.27.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -28,10 +32,9 @@ require (
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
-	github.com/spf13/pflag v1.0.6 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 	go.mongodb.org/mongo-driver v1.14.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/exp v0.0.0-20250103183323-7d7fa50e5329 // indirect
 	golang.org/x/sys v0.28.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,185 @@
+package attestation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/api/referrers"
+	"github.com/jcchavezs/nuro/internal/log"
+	"go.uber.org/zap"
+)
+
+const (
+	PredicateTypeAnnotation = "in-toto.io/predicate-type"
+
+	inTotoContentType = "application/vnd.in-toto+json"
+	dsseContentType   = "application/vnd.dsse.envelope.v1+json"
+)
+
+// Statement is an in-toto statement
+type Statement struct {
+	Type          string          `json:"_type"`
+	PredicateType string          `json:"predicateType"`
+	Subject       []Subject       `json:"subject"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// Subject is an artifact the statement refers to
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Statements returns the in-toto statements attached to the image manifest with the given
+// digest whose predicate type is accepted by the match function. It looks up the
+// attestation manifests BuildKit pushes into the index, if any, first, falling back to
+// the referrers of the image manifest.
+func Statements(ctx context.Context, registry string, insecure bool, name string, idx *manifest.Index, digest string, match func(predicateType string) bool) ([]Statement, error) {
+	statements, err := FromIndex(ctx, registry, insecure, name, idx, digest, match)
+	if err != nil || len(statements) > 0 {
+		return statements, err
+	}
+
+	descriptors, err := referrers.List(ctx, registry, insecure, name, digest, "")
+	if err != nil {
+		return nil, fmt.Errorf("listing referrers: %w", err)
+	}
+
+	for _, d := range descriptors {
+		s, err := fromDescriptor(ctx, registry, insecure, name, d, match)
+		if err != nil {
+			return nil, fmt.Errorf("getting statements from referrer: %w", err)
+		}
+
+		statements = append(statements, s...)
+	}
+
+	return statements, nil
+}
+
+// FromIndex returns the statements accepted by the match function in the attestation
+// manifests BuildKit pushes into the index for the image manifest with the given digest.
+// The index can be nil.
+func FromIndex(ctx context.Context, registry string, insecure bool, name string, idx *manifest.Index, digest string, match func(predicateType string) bool) ([]Statement, error) {
+	if idx == nil {
+		return nil, nil
+	}
+
+	for _, a := range idx.Attestations() {
+		if a.ImageDigest != digest {
+			continue
+		}
+
+		log.Logger.Debug("Found attestation manifest", zap.String("digest", a.Digest))
+
+		statements, err := fromDescriptor(ctx, registry, insecure, name, a.Descriptor, match)
+		if err != nil {
+			return nil, fmt.Errorf("getting statements from attestation manifest: %w", err)
+		}
+
+		if len(statements) > 0 {
+			return statements, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func fromDescriptor(ctx context.Context, registry string, insecure bool, name string, d manifest.Descriptor, match func(string) bool) ([]Statement, error) {
+	res, err := manifest.GetByDescriptor(ctx, registry, insecure, name, d)
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.IsManifest() {
+		return nil, nil
+	}
+
+	m, err := res.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	return FromManifest(ctx, registry, insecure, name, m, match)
+}
+
+// FromManifest downloads the layers of the manifest that hold statements accepted by
+// the match function. Layers annotated with a predicate type are only downloaded when
+// it matches.
+func FromManifest(ctx context.Context, registry string, insecure bool, name string, m *manifest.Manifest, match func(string) bool) ([]Statement, error) {
+	var statements []Statement
+	for _, l := range m.Layers {
+		if pt, ok := l.Annotations[PredicateTypeAnnotation]; ok && !match(pt) {
+			continue
+		}
+
+		if l.MediaType != inTotoContentType && l.MediaType != dsseContentType {
+			continue
+		}
+
+		s, err := getStatement(ctx, registry, insecure, name, l)
+		if err != nil {
+			return nil, err
+		}
+
+		if match(s.PredicateType) {
+			statements = append(statements, *s)
+		}
+	}
+
+	return statements, nil
+}
+
+func getStatement(ctx context.Context, registry string, insecure bool, name string, l manifest.Descriptor) (*Statement, error) {
+	r, err := blob.Get(ctx, registry, insecure, name, l.Digest, l.Size)
+	if err != nil {
+		return nil, fmt.Errorf("getting statement: %w", err)
+	}
+	defer r.Close() //nolint
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading statement: %w", err)
+	}
+
+	if l.MediaType == dsseContentType {
+		if b, err = dssePayload(b); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Statement{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("decoding statement: %w", err)
+	}
+
+	return s, nil
+}
+
+// dssePayload extracts the statement from a DSSE envelope. Signatures are not verified.
+func dssePayload(b []byte) ([]byte, error) {
+	var envelope struct {
+		PayloadType string `json:"payloadType"`
+		Payload     string `json:"payload"`
+	}
+
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		return nil, fmt.Errorf("decoding DSSE envelope: %w", err)
+	}
+
+	if envelope.PayloadType != inTotoContentType {
+		return nil, fmt.Errorf("unexpected DSSE payload type %q", envelope.PayloadType)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("decoding DSSE payload: %w", err)
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,93 @@
+package attestation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	imageDigest          = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	provenanceStatement  = `{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": "https://slsa.dev/provenance/v0.2", "predicate": {}}`
+	sbomStatement        = `{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": "https://spdx.dev/Document", "predicate": {}}`
+	ociManifestV1Content = "application/vnd.oci.image.manifest.v1+json"
+)
+
+type registry map[string]struct {
+	contentType string
+	body        string
+}
+
+// add stores the content in the registry and returns its descriptor as JSON
+func (r registry) add(kind, contentType, body, annotations string) string {
+	d := content.FromBytes([]byte(body))
+	r["/v2/app/"+kind+"/"+d] = struct {
+		contentType string
+		body        string
+	}{contentType, body}
+
+	return fmt.Sprintf(`{"mediaType": %q, "digest": %q, "size": %d, "annotations": {%s}}`, contentType, d, len(body), annotations)
+}
+
+func (r registry) serve() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c, ok := r[req.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", c.contentType)
+		_, _ = w.Write([]byte(c.body))
+	}))
+}
+
+func isProvenance(predicateType string) bool {
+	return predicateType == "https://slsa.dev/provenance/v0.2"
+}
+
+func TestStatementsFromAttestationManifest(t *testing.T) {
+	r := registry{}
+	provenance := r.add("blobs", inTotoContentType, provenanceStatement, `"in-toto.io/predicate-type": "https://slsa.dev/provenance/v0.2"`)
+	sbom := r.add("blobs", inTotoContentType, sbomStatement, `"in-toto.io/predicate-type": "https://spdx.dev/Document"`)
+	attestationManifest := r.add("manifests", ociManifestV1Content, `{"layers": [`+provenance+`, `+sbom+`]}`, `"vnd.docker.reference.type": "attestation-manifest", "vnd.docker.reference.digest": "`+imageDigest+`"`)
+
+	server := r.serve()
+	defer server.Close()
+
+	idx := &manifest.Index{}
+	require.NoError(t, json.Unmarshal([]byte(`{"manifests": [`+attestationManifest+`]}`), idx))
+
+	statements, err := Statements(context.Background(), server.URL[len("http://"):], true, "app", idx, imageDigest, isProvenance)
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	require.Equal(t, "https://slsa.dev/provenance/v0.2", statements[0].PredicateType)
+}
+
+func TestStatementsFromReferrers(t *testing.T) {
+	envelope := fmt.Sprintf(`{"payloadType": %q, "payload": %q, "signatures": []}`, inTotoContentType, base64.StdEncoding.EncodeToString([]byte(provenanceStatement)))
+
+	r := registry{}
+	dsse := r.add("blobs", dsseContentType, envelope, "")
+	referrer := r.add("manifests", ociManifestV1Content, `{"layers": [`+dsse+`]}`, "")
+	r["/v2/app/referrers/"+imageDigest] = struct {
+		contentType string
+		body        string
+	}{"application/vnd.oci.image.index.v1+json", `{"manifests": [` + referrer + `]}`}
+
+	server := r.serve()
+	defer server.Close()
+
+	statements, err := Statements(context.Background(), server.URL[len("http://"):], true, "app", nil, imageDigest, isProvenance)
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	require.Equal(t, "https://slsa.dev/provenance/v0.2", statements[0].PredicateType)
+}
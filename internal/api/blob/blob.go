@@ -1,25 +1,146 @@
 package blob
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
 	"github.com/jcchavezs/nuro/internal/api"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/content"
 	"github.com/jcchavezs/nuro/internal/http"
 )
 
+// ConfigBlob is the image config as described by the OCI image spec, including the
+// docker extensions like the healthcheck.
 type ConfigBlob struct {
-	Config struct {
-		Labels map[string]string `json:"labels"`
-	} `json:"config"`
-	Annotations map[string]string `json:"annotations"`
-	Created     time.Time         `json:"created"`
+	Created      time.Time `json:"created"`
+	Author       string    `json:"author,omitempty"`
+	Architecture string    `json:"architecture"`
+	OS           string    `json:"os"`
+	OSVersion    string    `json:"os.version,omitempty"`
+	Variant      string    `json:"variant,omitempty"`
+	Config       Config    `json:"config"`
+	RootFS       RootFS    `json:"rootfs"`
+	History      []History `json:"history,omitempty"`
+	// Annotations is not part of the OCI image config but some tools set it
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
-// GetConfigBlob gets the config blob using a digest
-func GetConfigBlob(ctx context.Context, registry string, insecure bool, name, digest string) (*ConfigBlob, error) {
+// Config holds the execution parameters used when running a container from the image
+type Config struct {
+	User         string              `json:"User,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	Volumes      map[string]struct{} `json:"Volumes,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	StopSignal   string              `json:"StopSignal,omitempty"`
+	Healthcheck  *Healthcheck        `json:"Healthcheck,omitempty"`
+	OnBuild      []string            `json:"OnBuild,omitempty"`
+}
+
+// Healthcheck describes how to check the container is healthy. Durations are encoded
+// in nanoseconds.
+type Healthcheck struct {
+	// Test is the check to run, e.g. ["CMD", "curl", "localhost"], ["CMD-SHELL", "..."]
+	// or ["NONE"] to disable the healthcheck inherited from the base image.
+	Test          []string      `json:"Test,omitempty"`
+	Interval      time.Duration `json:"Interval,omitempty"`
+	Timeout       time.Duration `json:"Timeout,omitempty"`
+	StartPeriod   time.Duration `json:"StartPeriod,omitempty"`
+	StartInterval time.Duration `json:"StartInterval,omitempty"`
+	Retries       int           `json:"Retries,omitempty"`
+}
+
+// RootFS references the layers of the image by their uncompressed digests
+type RootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+// Platform returns the platform the image was built for
+func (c *ConfigBlob) Platform() manifest.Platform {
+	return manifest.Platform{
+		Architecture: c.Architecture,
+		OS:           c.OS,
+		OSVersion:    c.OSVersion,
+		Variant:      c.Variant,
+	}
+}
+
+// History describes how a layer of the image was built
+type History struct {
+	Created    time.Time `json:"created,omitempty"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	Author     string    `json:"author,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	EmptyLayer bool      `json:"empty_layer,omitempty"`
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Get streams a blob verifying its content against the digest and the size as it is
+// read. A non positive size skips the size verification. Verification only completes
+// once the blob is read until EOF.
+func Get(ctx context.Context, registry string, insecure bool, name, digest string, size int64) (io.ReadCloser, error) {
+	res, err := get(ctx, registry, insecure, name, digest, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if size <= 0 {
+		size = -1
+	}
+
+	r, err := content.NewVerifyingReader(res.Body, size, digest, res.Header.Get("Docker-Content-Digest"))
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("verifying response: %w", err)
+	}
+
+	return readCloser{r, res.Body}, nil
+}
+
+// GetFrom streams a blob starting at the given offset using a range request, e.g. to
+// resume a download. It returns the offset the content actually starts at, which is 0
+// when the registry does not support range requests, and the size of the whole blob or
+// -1 when unknown. The content is not verified as only part of it is read.
+func GetFrom(ctx context.Context, registry string, insecure bool, name, digest string, offset int64) (io.ReadCloser, int64, int64, error) {
+	res, err := get(ctx, registry, insecure, name, digest, offset)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	if res.StatusCode != http.StatusPartialContent {
+		return res.Body, 0, res.ContentLength, nil
+	}
+
+	// Content-Range: bytes <start>-<end>/<size>
+	var start, end, size int64
+	if _, err := fmt.Sscanf(res.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &size); err != nil {
+		size = -1
+		start = offset
+	}
+
+	if start != offset {
+		_ = res.Body.Close()
+		return nil, 0, 0, fmt.Errorf("unexpected content range %q", res.Header.Get("Content-Range"))
+	}
+
+	return res.Body, start, size, nil
+}
+
+func get(ctx context.Context, registry string, insecure bool, name, digest string, offset int64) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(
 		ctx, "GET",
 		fmt.Sprintf("%s://%s/v2/%s/blobs/%s", http.ResolveProtocol(insecure), registry, name, digest),
@@ -29,26 +150,178 @@ func GetConfigBlob(ctx context.Context, registry string, insecure bool, name, di
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
 	res, err := http.Client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("doing request: %w", err)
 	}
-	defer res.Body.Close() //nolint
 
-	if res.StatusCode != http.StatusOK {
-		var errRes api.ErrorResponse
-		if err := json.NewDecoder(res.Body).Decode(&errRes); err != nil {
-			return nil, fmt.Errorf("decoding error response: %w", err)
-		}
+	if res.StatusCode != http.StatusOK && (offset == 0 || res.StatusCode != http.StatusPartialContent) {
+		defer res.Body.Close() //nolint
+		return nil, api.NewStatusError(res.StatusCode, res.Body)
+	}
+
+	return res, nil
+}
 
-		return nil, fmt.Errorf("unexpected status code %d: %w", res.StatusCode, errRes.Error())
+// GetConfigBlob gets the config blob using a digest
+func GetConfigBlob(ctx context.Context, registry string, insecure bool, name, digest string, size int64) (*ConfigBlob, error) {
+	r, err := Get(ctx, registry, insecure, name, digest, size)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() //nolint
+
+	// the whole blob is read before decoding so the content gets verified
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
 	}
 
 	c := &ConfigBlob{}
 
-	if err := json.NewDecoder(res.Body).Decode(c); err != nil {
+	if err := json.Unmarshal(b, c); err != nil {
 		return nil, fmt.Errorf("decoding response: %w", err)
 	}
 
 	return c, nil
 }
+
+// GetConfig gets the config of a resolved image. For deprecated schema1 manifests the
+// config is built out of the manifest history as they do not have a config blob. It
+// fails with manifest.ErrNotImage when the manifest describes a non image artifact.
+func GetConfig(ctx context.Context, registry string, insecure bool, name string, m *manifest.Resolved) (*ConfigBlob, error) {
+	if m.Schema1 != nil {
+		return configFromSchema1(m.Schema1)
+	}
+
+	if !m.Manifest.IsImage() {
+		return nil, fmt.Errorf("%w, artifact type is %q", manifest.ErrNotImage, m.Manifest.Type())
+	}
+
+	return GetConfigBlob(ctx, registry, insecure, name, m.Manifest.Config.Digest, m.Manifest.Config.Size)
+}
+
+// configFromSchema1 builds the config out of the v1Compatibility entries of a schema1
+// manifest, being the top entry the one holding the config of the image.
+func configFromSchema1(s *manifest.Schema1) (*ConfigBlob, error) {
+	c := &ConfigBlob{}
+	if err := json.Unmarshal([]byte(s.History[0].V1Compatibility), c); err != nil {
+		return nil, fmt.Errorf("decoding v1 compatibility config: %w", err)
+	}
+
+	c.History = make([]History, 0, len(s.History))
+	for i := len(s.History) - 1; i >= 0; i-- {
+		var v1 struct {
+			Created         time.Time `json:"created"`
+			Author          string    `json:"author"`
+			Comment         string    `json:"comment"`
+			Throwaway       bool      `json:"throwaway"`
+			ContainerConfig struct {
+				Cmd []string `json:"Cmd"`
+			} `json:"container_config"`
+		}
+
+		if err := json.Unmarshal([]byte(s.History[i].V1Compatibility), &v1); err != nil {
+			return nil, fmt.Errorf("decoding v1 compatibility history: %w", err)
+		}
+
+		c.History = append(c.History, History{
+			Created:    v1.Created,
+			CreatedBy:  strings.Join(v1.ContainerConfig.Cmd, " "),
+			Author:     v1.Author,
+			Comment:    v1.Comment,
+			EmptyLayer: v1.Throwaway,
+		})
+	}
+
+	return c, nil
+}
+
+// Exists checks whether the blob with the given digest exists in the repository
+func Exists(ctx context.Context, registry string, insecure bool, name, digest string) (bool, error) {
+	req, err := http.NewRequestWithContext(
+		ctx, "HEAD",
+		fmt.Sprintf("%s://%s/v2/%s/blobs/%s", http.ResolveProtocol(insecure), registry, name, digest),
+		nil,
+	)
+	if err != nil {
+		return false, fmt.Errorf("creating request: %w", err)
+	}
+
+	res, err := http.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("doing request: %w", err)
+	}
+	defer res.Body.Close() //nolint
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+
+	return false, api.NewStatusError(res.StatusCode, nil)
+}
+
+// Upload uploads the content as a blob in a single request unless it already exists
+// in the repository, and returns its digest.
+func Upload(ctx context.Context, registry string, insecure bool, name string, b []byte) (string, error) {
+	digest := content.FromBytes(b)
+
+	if exists, err := Exists(ctx, registry, insecure, name, digest); err != nil {
+		return "", err
+	} else if exists {
+		return digest, nil
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, "POST",
+		fmt.Sprintf("%s://%s/v2/%s/blobs/uploads/", http.ResolveProtocol(insecure), registry, name),
+		nil,
+	)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+
+	res, err := http.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("doing request: %w", err)
+	}
+	defer res.Body.Close() //nolint
+
+	if res.StatusCode != http.StatusAccepted {
+		return "", api.NewStatusError(res.StatusCode, res.Body)
+	}
+
+	location, err := req.URL.Parse(res.Header.Get("Location"))
+	if err != nil {
+		return "", fmt.Errorf("parsing upload location: %w", err)
+	}
+
+	q := location.Query()
+	q.Set("digest", digest)
+	location.RawQuery = q.Encode()
+
+	req, err = http.NewRequestWithContext(ctx, "PUT", location.String(), bytes.NewReader(b))
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	res, err = http.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("doing request: %w", err)
+	}
+	defer res.Body.Close() //nolint
+
+	if res.StatusCode != http.StatusCreated {
+		return "", api.NewStatusError(res.StatusCode, res.Body)
+	}
+
+	return digest, nil
+}
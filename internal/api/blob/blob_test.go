@@ -2,10 +2,14 @@ package blob
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/content"
 	"github.com/stretchr/testify/require"
 )
 
@@ -15,6 +19,7 @@ func TestGetLabelsFromBlob(t *testing.T) {
 		nameParam      string
 		digest         string
 		mockResponse   string
+		mockDigest     string
 		mockStatusCode int
 		expectedLabels map[string]string
 		expectErr      bool
@@ -22,7 +27,6 @@ func TestGetLabelsFromBlob(t *testing.T) {
 		{
 			name:           "valid response with labels",
 			nameParam:      "library/nginx",
-			digest:         "sha256:abc123",
 			mockResponse:   `{"config": {"labels": {"key1": "value1", "key2": "value2"}}}`,
 			mockStatusCode: http.StatusOK,
 			expectedLabels: map[string]string{"key1": "value1", "key2": "value2"},
@@ -31,7 +35,6 @@ func TestGetLabelsFromBlob(t *testing.T) {
 		{
 			name:           "valid response with no labels",
 			nameParam:      "library/nginx",
-			digest:         "sha256:abc123",
 			mockResponse:   `{"config": {"labels": {}}}`,
 			mockStatusCode: http.StatusOK,
 			expectedLabels: map[string]string{},
@@ -40,16 +43,32 @@ func TestGetLabelsFromBlob(t *testing.T) {
 		{
 			name:           "error response from server",
 			nameParam:      "library/nginx",
-			digest:         "sha256:abc123",
 			mockResponse:   `{"errors": [{"message": "not found"}]}`,
 			mockStatusCode: http.StatusNotFound,
 			expectedLabels: nil,
 			expectErr:      true,
 		},
+		{
+			name:           "content not matching the digest",
+			nameParam:      "library/nginx",
+			digest:         content.FromBytes([]byte(`{"config": {"labels": {}}}`)),
+			mockResponse:   `{"config": {"labels": {"key1": "value1"}}}`,
+			mockStatusCode: http.StatusOK,
+			expectedLabels: nil,
+			expectErr:      true,
+		},
+		{
+			name:           "content not matching the Docker-Content-Digest header",
+			nameParam:      "library/nginx",
+			mockResponse:   `{"config": {"labels": {}}}`,
+			mockDigest:     content.FromBytes([]byte(`{"config": {"labels": {"key1": "value1"}}}`)),
+			mockStatusCode: http.StatusOK,
+			expectedLabels: nil,
+			expectErr:      true,
+		},
 		{
 			name:           "invalid JSON response",
 			nameParam:      "library/nginx",
-			digest:         "sha256:abc123",
 			mockResponse:   `invalid-json`,
 			mockStatusCode: http.StatusOK,
 			expectedLabels: nil,
@@ -59,9 +78,16 @@ func TestGetLabelsFromBlob(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			if tt.digest == "" {
+				tt.digest = content.FromBytes([]byte(tt.mockResponse))
+			}
+
 			// Mock HTTP server
 			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				require.Equal(t, "/v2/"+tt.nameParam+"/blobs/"+tt.digest, r.URL.Path)
+				if tt.mockDigest != "" {
+					w.Header().Set("Docker-Content-Digest", tt.mockDigest)
+				}
 				w.WriteHeader(tt.mockStatusCode)
 				_, _ = w.Write([]byte(tt.mockResponse))
 			}))
@@ -71,7 +97,7 @@ func TestGetLabelsFromBlob(t *testing.T) {
 			registry := server.URL[len("http://"):]
 
 			// Call the function
-			c, err := GetConfigBlob(context.Background(), registry, true, tt.nameParam, tt.digest)
+			c, err := GetConfigBlob(context.Background(), registry, true, tt.nameParam, tt.digest, -1)
 
 			// Validate results
 			if tt.expectErr {
@@ -83,3 +109,88 @@ func TestGetLabelsFromBlob(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigFromSchema1(t *testing.T) {
+	s := &manifest.Schema1{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"history": [
+			{"v1Compatibility": "{\"created\":\"2016-01-01T00:00:00Z\",\"config\":{\"Labels\":{\"key1\":\"value1\"}},\"container_config\":{\"Cmd\":[\"/bin/sh\",\"-c\",\"#(nop) LABEL key1=value1\"]},\"throwaway\":true}"},
+			{"v1Compatibility": "{\"created\":\"2015-01-01T00:00:00Z\",\"container_config\":{\"Cmd\":[\"/bin/sh\",\"-c\",\"#(nop) ADD file:abc in /\"]}}"}
+		]
+	}`), s))
+
+	c, err := configFromSchema1(s)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), c.Created)
+	require.Equal(t, map[string]string{"key1": "value1"}, c.Config.Labels)
+	require.Equal(t, []History{
+		{
+			Created:   time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC),
+			CreatedBy: "/bin/sh -c #(nop) ADD file:abc in /",
+		},
+		{
+			Created:    time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+			CreatedBy:  "/bin/sh -c #(nop) LABEL key1=value1",
+			EmptyLayer: true,
+		},
+	}, c.History)
+}
+
+func TestGetConfigNotImage(t *testing.T) {
+	m := &manifest.Resolved{
+		Manifest: &manifest.Manifest{
+			Config: manifest.Descriptor{MediaType: "application/vnd.cncf.helm.config.v1+json"},
+		},
+	}
+
+	_, err := GetConfig(context.Background(), "localhost", true, "charts/app", m)
+	require.ErrorIs(t, err, manifest.ErrNotImage)
+}
+
+func TestConfigBlobDecoding(t *testing.T) {
+	c := &ConfigBlob{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"created": "2024-01-01T00:00:00Z",
+		"author": "jane",
+		"architecture": "arm64",
+		"os": "linux",
+		"variant": "v8",
+		"config": {
+			"User": "nobody",
+			"ExposedPorts": {"8080/tcp": {}},
+			"Env": ["PATH=/usr/bin"],
+			"Entrypoint": ["/app"],
+			"Cmd": ["--help"],
+			"Volumes": {"/data": {}},
+			"WorkingDir": "/srv",
+			"Labels": {"key1": "value1"},
+			"StopSignal": "SIGTERM",
+			"Healthcheck": {"Test": ["CMD", "/app", "health"], "Interval": 30000000000, "Retries": 3},
+			"OnBuild": ["RUN make"]
+		},
+		"rootfs": {"type": "layers", "diff_ids": ["sha256:abc"]},
+		"history": [{"created_by": "COPY app /app"}]
+	}`), c))
+
+	require.Equal(t, manifest.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"}, c.Platform())
+	require.Equal(t, "jane", c.Author)
+	require.Equal(t, Config{
+		User:         "nobody",
+		ExposedPorts: map[string]struct{}{"8080/tcp": {}},
+		Env:          []string{"PATH=/usr/bin"},
+		Entrypoint:   []string{"/app"},
+		Cmd:          []string{"--help"},
+		Volumes:      map[string]struct{}{"/data": {}},
+		WorkingDir:   "/srv",
+		Labels:       map[string]string{"key1": "value1"},
+		StopSignal:   "SIGTERM",
+		Healthcheck: &Healthcheck{
+			Test:     []string{"CMD", "/app", "health"},
+			Interval: 30 * time.Second,
+			Retries:  3,
+		},
+		OnBuild: []string{"RUN make"},
+	}, c.Config)
+	require.Equal(t, RootFS{Type: "layers", DiffIDs: []string{"sha256:abc"}}, c.RootFS)
+	require.Len(t, c.History, 1)
+}
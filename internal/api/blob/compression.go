@@ -0,0 +1,78 @@
+package blob
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the compression algorithm of a layer
+type Compression string
+
+const (
+	Uncompressed Compression = ""
+	Gzip         Compression = "gzip"
+	Zstd         Compression = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// CompressionFromMediaType returns the compression of a layer given its media type, e.g.
+// application/vnd.oci.image.layer.v1.tar+zstd. The second value is false when the media
+// type does not tell, e.g. for non layer blobs.
+func CompressionFromMediaType(mediaType string) (Compression, bool) {
+	switch {
+	case strings.HasSuffix(mediaType, "+gzip"), strings.HasSuffix(mediaType, ".tar.gzip"):
+		return Gzip, true
+	case strings.HasSuffix(mediaType, "+zstd"):
+		return Zstd, true
+	case strings.HasSuffix(mediaType, ".tar"), strings.HasSuffix(mediaType, ".tar.v1"):
+		return Uncompressed, true
+	}
+
+	return Uncompressed, false
+}
+
+// Decompress decompresses the layer based on its media type, falling back to detect the
+// compression from the content when the media type is empty or unknown.
+func Decompress(r io.Reader, mediaType string) (io.ReadCloser, error) {
+	c, ok := CompressionFromMediaType(mediaType)
+	if !ok {
+		br := bufio.NewReader(r)
+		magic, _ := br.Peek(len(zstdMagic))
+		switch {
+		case bytes.HasPrefix(magic, gzipMagic):
+			c = Gzip
+		case bytes.HasPrefix(magic, zstdMagic):
+			c = Zstd
+		}
+		r = br
+	}
+
+	switch c {
+	case Gzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("creating gzip reader: %w", err)
+		}
+
+		return gr, nil
+	case Zstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("creating zstd reader: %w", err)
+		}
+
+		return zr.IOReadCloser(), nil
+	}
+
+	return io.NopCloser(r), nil
+}
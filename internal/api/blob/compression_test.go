@@ -0,0 +1,99 @@
+package blob
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompress(t *testing.T) {
+	payload := []byte("layer content")
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err := gw.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	var zs bytes.Buffer
+	zw, err := zstd.NewWriter(&zs)
+	require.NoError(t, err)
+	_, err = zw.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	tests := []struct {
+		name      string
+		mediaType string
+		content   []byte
+	}{
+		{name: "gzip media type", mediaType: "application/vnd.oci.image.layer.v1.tar+gzip", content: gz.Bytes()},
+		{name: "docker gzip media type", mediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip", content: gz.Bytes()},
+		{name: "zstd media type", mediaType: "application/vnd.oci.image.layer.v1.tar+zstd", content: zs.Bytes()},
+		{name: "uncompressed media type", mediaType: "application/vnd.oci.image.layer.v1.tar", content: payload},
+		{name: "detected gzip", content: gz.Bytes()},
+		{name: "detected zstd", content: zs.Bytes()},
+		{name: "detected uncompressed", content: payload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Decompress(bytes.NewReader(tt.content), tt.mediaType)
+			require.NoError(t, err)
+			defer r.Close() //nolint
+
+			b, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, payload, b)
+		})
+	}
+}
+
+func TestGetFrom(t *testing.T) {
+	payload := []byte("0123456789")
+	digest := content.FromBytes(payload)
+
+	tests := []struct {
+		name          string
+		supportsRange bool
+		offset        int64
+		expectedStart int64
+		expectedBody  string
+	}{
+		{name: "range supported", supportsRange: true, offset: 4, expectedStart: 4, expectedBody: "456789"},
+		{name: "range not supported", offset: 4, expectedStart: 0, expectedBody: "0123456789"},
+		{name: "from the start", supportsRange: true, expectedStart: 0, expectedBody: "0123456789"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tt.supportsRange {
+					r.Header.Del("Range")
+				}
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(payload))
+			}))
+			defer server.Close()
+
+			r, start, size, err := GetFrom(context.Background(), server.URL[len("http://"):], true, "library/nginx", digest, tt.offset)
+			require.NoError(t, err)
+			defer r.Close() //nolint
+
+			require.Equal(t, tt.expectedStart, start)
+			require.Equal(t, int64(len(payload)), size)
+
+			b, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedBody, string(b))
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package api
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
 
 // ErrorResponse represents an error response from a OCI server
 type ErrorResponse struct {
@@ -21,3 +27,44 @@ func (r ErrorResponse) Error() error {
 
 	return errors.Join(errs...)
 }
+
+// StatusError is returned when the server responds with an unexpected status code
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("unexpected status code %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("unexpected status code %d: %v", e.StatusCode, e.Err)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound returns true if the error is a 404 status error
+func IsNotFound(err error) bool {
+	var sErr *StatusError
+	return errors.As(err, &sErr) && sErr.StatusCode == http.StatusNotFound
+}
+
+// NewStatusError builds a StatusError out of the status code and the body of the
+// response. Bodies that are not a valid error response are ignored.
+func NewStatusError(statusCode int, body io.Reader) error {
+	sErr := &StatusError{StatusCode: statusCode}
+
+	var errRes ErrorResponse
+	if body != nil && json.NewDecoder(body).Decode(&errRes) == nil {
+		sErr.Err = errRes.Error()
+	}
+
+	if sErr.Err == nil {
+		sErr.Err = errors.New(http.StatusText(statusCode))
+	}
+
+	return sErr
+}
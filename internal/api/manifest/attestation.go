@@ -0,0 +1,60 @@
+package manifest
+
+const (
+	referenceTypeAnnotation   = "vnd.docker.reference.type"
+	referenceDigestAnnotation = "vnd.docker.reference.digest"
+
+	attestationManifestReferenceType = "attestation-manifest"
+)
+
+// IsAttestation returns true if the descriptor points to an attestation manifest
+// pushed by BuildKit into the index rather than to an image manifest.
+func (d Descriptor) IsAttestation() bool {
+	return d.Annotations[referenceTypeAnnotation] == attestationManifestReferenceType
+}
+
+// Images returns the image manifests in the index
+func (idx *Index) Images() []Descriptor {
+	images := make([]Descriptor, 0, len(idx.Manifests))
+	for _, m := range idx.Manifests {
+		if !m.IsAttestation() {
+			images = append(images, m)
+		}
+	}
+
+	return images
+}
+
+// Attestation is an attestation manifest along with the image manifest it attests
+type Attestation struct {
+	Descriptor
+	// ImageDigest is the digest of the image manifest the attestation belongs to
+	ImageDigest string
+	// Subject is the image manifest the attestation belongs to, nil when the index
+	// does not contain the referenced manifest.
+	Subject *Descriptor
+}
+
+// Attestations returns the attestation manifests in the index
+func (idx *Index) Attestations() []Attestation {
+	images := map[string]Descriptor{}
+	for _, m := range idx.Images() {
+		images[m.Digest] = m
+	}
+
+	var attestations []Attestation
+	for _, m := range idx.Manifests {
+		if !m.IsAttestation() {
+			continue
+		}
+
+		a := Attestation{Descriptor: m, ImageDigest: m.Annotations[referenceDigestAnnotation]}
+		if subject, ok := images[a.ImageDigest]; ok {
+			a.Subject = &subject
+		}
+
+		attestations = append(attestations, a)
+	}
+
+	return attestations
+}
@@ -0,0 +1,49 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexAttestations(t *testing.T) {
+	amd64 := Descriptor{Digest: "sha256:amd64", Platform: &Platform{OS: "linux", Architecture: "amd64"}}
+	arm64 := Descriptor{Digest: "sha256:arm64", Platform: &Platform{OS: "linux", Architecture: "arm64"}}
+	attestation := func(digest, subject string) Descriptor {
+		return Descriptor{
+			Digest:   digest,
+			Platform: &Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{
+				referenceTypeAnnotation:   attestationManifestReferenceType,
+				referenceDigestAnnotation: subject,
+			},
+		}
+	}
+
+	// attestations listed first to make sure they are never selected
+	idx := &Index{
+		Manifests: []Descriptor{
+			attestation("sha256:att-amd64", "sha256:amd64"),
+			amd64,
+			arm64,
+			attestation("sha256:att-arm64", "sha256:arm64"),
+			attestation("sha256:att-missing", "sha256:missing"),
+		},
+	}
+
+	require.Equal(t, []Descriptor{amd64, arm64}, idx.Images())
+
+	d, err := SelectManifest(idx, nil)
+	require.NoError(t, err)
+	require.Equal(t, "sha256:amd64", d.Digest)
+
+	_, err = SelectManifest(idx, &Platform{OS: "unknown", Architecture: "unknown"})
+	require.ErrorIs(t, err, ErrPlatformNotFound)
+
+	attestations := idx.Attestations()
+	require.Len(t, attestations, 3)
+	require.Equal(t, "sha256:att-amd64", attestations[0].Digest)
+	require.Equal(t, &amd64, attestations[0].Subject)
+	require.Equal(t, &arm64, attestations[1].Subject)
+	require.Nil(t, attestations[2].Subject)
+}
@@ -1,39 +1,170 @@
 package manifest
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/jcchavezs/nuro/internal/api"
+	"github.com/jcchavezs/nuro/internal/content"
 	"github.com/jcchavezs/nuro/internal/http"
 	"github.com/jcchavezs/nuro/internal/log"
 	"go.uber.org/zap"
 )
 
-// GetConfigDigestFromManifest gets the digest of the config from the manifest
-func GetConfigDigestFromManifest(ctx context.Context, registry string, insecure bool, name, reference string) (string, error) {
-	var (
-		digest string
-		err    error
-	)
+const (
+	manifestV2ContentType     = "application/vnd.docker.distribution.manifest.v2+json"
+	manifestListV2ContentType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	OCIManifestV1ContentType  = "application/vnd.oci.image.manifest.v1+json"
+	OCIIndexV1ContentType     = "application/vnd.oci.image.index.v1+json"
+)
+
+const (
+	OCIConfigV1ContentType    = "application/vnd.oci.image.config.v1+json"
+	dockerConfigV1ContentType = "application/vnd.docker.container.image.v1+json"
+	// OCIEmptyContentType is the media type of the empty config used by artifacts
+	OCIEmptyContentType = "application/vnd.oci.empty.v1+json"
+)
+
+// ErrNotImage is returned when a manifest describes an artifact rather than an image
+var ErrNotImage = errors.New("not a container image")
+
+// acceptedContentTypes are the manifest media types nuro knows how to handle
+var acceptedContentTypes = []string{
+	OCIIndexV1ContentType,
+	manifestListV2ContentType,
+	OCIManifestV1ContentType,
+	manifestV2ContentType,
+	signedManifestV1ContentType,
+	manifestV1ContentType,
+}
+
+// Descriptor describes a piece of content addressed by its digest
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	Platform     *Platform         `json:"platform,omitempty"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// Platform describes the platform an image manifest runs on
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	OSVersion    string `json:"os.version,omitempty"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Manifest is an image manifest, either OCI or docker v2
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// IsImage returns true if the manifest describes a container image rather than a non
+// image artifact, e.g. a helm chart or a WASM module.
+func (m *Manifest) IsImage() bool {
+	if m.ArtifactType != "" {
+		return false
+	}
+
+	switch m.Config.MediaType {
+	case OCIConfigV1ContentType, dockerConfigV1ContentType, "":
+		return true
+	}
+
+	return false
+}
+
+// Type returns the type of the artifact described by the manifest, which is its
+// artifactType or the media type of its config when missing.
+func (m *Manifest) Type() string {
+	if m.ArtifactType != "" {
+		return m.ArtifactType
+	}
+
+	return m.Config.MediaType
+}
+
+// Index is an image index or a docker manifest list
+type Index struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Manifests     []Descriptor      `json:"manifests"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Response is a manifest as served by the registry
+type Response struct {
+	MediaType string
+	Digest    string
+	Body      []byte
+}
 
-	digest, err = GetConfigDigestFromManifestSingle(ctx, registry, insecure, name, reference)
-	if err == nil {
-		return digest, nil
+// IsIndex returns true if the response is an image index or a manifest list
+func (r *Response) IsIndex() bool {
+	return r.MediaType == OCIIndexV1ContentType || r.MediaType == manifestListV2ContentType
+}
+
+// IsManifest returns true if the response is an image manifest
+func (r *Response) IsManifest() bool {
+	return r.MediaType == OCIManifestV1ContentType || r.MediaType == manifestV2ContentType
+}
+
+// Index decodes the response as an image index
+func (r *Response) Index() (*Index, error) {
+	if !r.IsIndex() {
+		return nil, fmt.Errorf("unexpected content type %q for index", r.MediaType)
+	}
+
+	idx := &Index{}
+	if err := json.Unmarshal(r.Body, idx); err != nil {
+		return nil, fmt.Errorf("decoding index: %w", err)
+	}
+
+	return idx, nil
+}
+
+// Manifest decodes the response as an image manifest
+func (r *Response) Manifest() (*Manifest, error) {
+	if !r.IsManifest() {
+		return nil, fmt.Errorf("unexpected content type %q for manifest", r.MediaType)
 	}
 
-	digest, err = GetConfigDigestFromManifestList(ctx, registry, insecure, name, reference)
-	if err == nil {
-		return digest, nil
+	m := &Manifest{}
+	if err := json.Unmarshal(r.Body, m); err != nil {
+		return nil, fmt.Errorf("decoding manifest: %w", err)
 	}
 
-	return digest, err
+	return m, nil
+}
+
+// Get fetches a manifest in a single request accepting every supported media type.
+// When the reference is a digest the content is verified against it.
+func Get(ctx context.Context, registry string, insecure bool, name, reference string) (*Response, error) {
+	return get(ctx, registry, insecure, name, reference, -1)
 }
 
-// GetConfigDigestFromManifestList gets the digest of the config from a list manifest
-func GetConfigDigestFromManifestList(ctx context.Context, registry string, insecure bool, name, reference string) (string, error) {
+// GetByDescriptor fetches the manifest described by the descriptor verifying its
+// content against the descriptor digest and size.
+func GetByDescriptor(ctx context.Context, registry string, insecure bool, name string, d Descriptor) (*Response, error) {
+	return get(ctx, registry, insecure, name, d.Digest, d.Size)
+}
+
+func get(ctx context.Context, registry string, insecure bool, name, reference string, size int64) (*Response, error) {
 	req, err := http.NewRequestWithContext(
 		ctx,
 		"GET",
@@ -41,126 +172,257 @@ func GetConfigDigestFromManifestList(ctx context.Context, registry string, insec
 		nil,
 	)
 	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
-	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.list.v2+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.index.v1+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.manifest.v1+json")
-	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Accept", strings.Join(acceptedContentTypes, ", "))
 
 	res, err := http.Client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("doing request: %w", err)
+		return nil, fmt.Errorf("doing request: %w", err)
 	}
 	defer res.Body.Close() //nolint
 
 	if res.StatusCode != http.StatusOK {
-		var errRes api.ErrorResponse
-		if err := json.NewDecoder(res.Body).Decode(&errRes); err != nil {
-			return "", fmt.Errorf("decoding error response: %w", err)
-		}
+		return nil, api.NewStatusError(res.StatusCode, res.Body)
+	}
 
-		return "", fmt.Errorf("unexpected status code %d: %w", res.StatusCode, errRes.Error())
+	var referenceDigest string
+	if content.IsDigest(reference) {
+		referenceDigest = reference
 	}
 
-	switch res.Header.Get("Content-Type") {
-	case ociImageV1ContentType:
-		m := manifestList{}
+	if size <= 0 {
+		size = -1
+	}
 
-		if err := json.NewDecoder(res.Body).Decode(&m); err != nil {
-			return "", fmt.Errorf("decoding response: %w", err)
-		}
+	r, err := content.NewVerifyingReader(res.Body, size)
+	if err != nil {
+		return nil, fmt.Errorf("verifying response: %w", err)
+	}
 
-		return m.Manifests[0].Digest, nil
-	case manifestV2ContentType:
-		m := manifest{}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
 
-		if err := json.NewDecoder(res.Body).Decode(&m); err != nil {
-			return "", fmt.Errorf("decoding response: %w", err)
+	mediaType := resolveMediaType(res.Header.Get("Content-Type"), body)
+
+	// the digest of signed schema1 manifests is calculated without the signatures
+	payload := body
+	if mediaType == signedManifestV1ContentType {
+		if payload, err = schema1Payload(body); err != nil {
+			return nil, fmt.Errorf("verifying response: %w", err)
 		}
+	}
 
-		return m.Config.Digest, nil
+	digest := res.Header.Get("Docker-Content-Digest")
+	if err := content.Verify(payload, -1, referenceDigest, digest); err != nil {
+		return nil, fmt.Errorf("verifying response: %w", err)
 	}
-	return "", errors.New("unexpected content type")
-}
 
-type manifest struct {
-	Config struct {
-		Digest string `json:"digest"`
-	} `json:"config"`
-}
+	if digest == "" {
+		digest = referenceDigest
+	}
 
-type manifestList struct {
-	Manifests []struct {
-		Digest string `json:"digest"`
-	} `json:"manifests"`
-}
+	if digest == "" {
+		digest = content.FromBytes(payload)
+	}
 
-const (
-	manifestV2ContentType     = "application/vnd.docker.distribution.manifest.v2+json"
-	manifestListV2ContentType = "application/vnd.docker.distribution.manifest.list.v2+json"
-	ociImageV1ContentType     = "application/vnd.oci.image.index.v1+json"
-)
+	return &Response{
+		MediaType: mediaType,
+		Digest:    digest,
+		Body:      body,
+	}, nil
+}
 
-// GetConfigDigestFromManifestSingle gets the digest of the config from a single manifest
-func GetConfigDigestFromManifestSingle(ctx context.Context, registry string, insecure bool, name, reference string) (string, error) {
+// Head fetches the descriptor of a manifest without downloading it. When the registry
+// does not return the digest it falls back to downloading and hashing the manifest.
+func Head(ctx context.Context, registry string, insecure bool, name, reference string) (Descriptor, error) {
 	req, err := http.NewRequestWithContext(
 		ctx,
-		"GET",
+		"HEAD",
 		fmt.Sprintf("%s://%s/v2/%s/manifests/%s", http.ResolveProtocol(insecure), registry, name, reference),
 		nil,
 	)
 	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
+		return Descriptor{}, fmt.Errorf("creating request: %w", err)
 	}
 
-	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.list.v2+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.index.v1+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.manifest.v1+json")
-	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Accept", strings.Join(acceptedContentTypes, ", "))
 
 	res, err := http.Client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("doing request: %w", err)
+		return Descriptor{}, fmt.Errorf("doing request: %w", err)
 	}
 	defer res.Body.Close() //nolint
 
 	if res.StatusCode != http.StatusOK {
-		var errRes api.ErrorResponse
-		if err := json.NewDecoder(res.Body).Decode(&errRes); err != nil {
-			return "", fmt.Errorf("decoding error response: %w", err)
+		return Descriptor{}, api.NewStatusError(res.StatusCode, nil)
+	}
+
+	d := Descriptor{
+		MediaType: resolveMediaType(res.Header.Get("Content-Type"), nil),
+		Digest:    res.Header.Get("Docker-Content-Digest"),
+		Size:      res.ContentLength,
+	}
+
+	if content.IsDigest(reference) && d.Digest != "" && d.Digest != reference {
+		return Descriptor{}, &content.VerificationError{Field: "digest", Expected: reference, Actual: d.Digest}
+	}
+
+	if d.Digest == "" || d.MediaType == "" {
+		log.Logger.Debug("Missing manifest digest in HEAD response, falling back to GET")
+
+		m, err := Get(ctx, registry, insecure, name, reference)
+		if err != nil {
+			return Descriptor{}, err
 		}
 
-		return "", fmt.Errorf("unexpected status code %d: %w", res.StatusCode, errRes.Error())
+		d = Descriptor{MediaType: m.MediaType, Digest: m.Digest, Size: int64(len(m.Body))}
 	}
-	contentType := res.Header.Get("Content-Type")
-	switch contentType {
-	case manifestV2ContentType:
-		m := manifest{}
 
-		if err := json.NewDecoder(res.Body).Decode(&m); err != nil {
-			return "", fmt.Errorf("decoding response: %w", err)
+	return d, nil
+}
+
+// resolveMediaType returns the media type of the manifest based on the content type
+// header, falling back to the mediaType field in the body when the registry serves
+// a generic content type.
+func resolveMediaType(contentType string, body []byte) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+
+	for _, ct := range acceptedContentTypes {
+		if mediaType == ct {
+			return mediaType
 		}
+	}
 
-		return m.Config.Digest, nil
-	case manifestListV2ContentType:
-		m := manifestList{}
+	var m struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(body, &m); err == nil && m.MediaType != "" {
+		return m.MediaType
+	}
+
+	return mediaType
+}
+
+// Resolved is the image manifest a reference resolves to
+type Resolved struct {
+	// Index is the index the manifest was selected from, nil when the reference
+	// points directly to an image manifest.
+	Index       *Index
+	IndexDigest string
+	Manifest    *Manifest
+	Digest      string
+	MediaType   string
+	// Schema1 is the original manifest when the image uses the deprecated schema1
+	// format, in which case Manifest only holds its layers.
+	Schema1 *Schema1
+}
+
+// Resolve resolves a reference into an image manifest, following the index children
+// only when the reference points to an index. When platform is nil the first manifest
+// in the index is selected.
+func Resolve(ctx context.Context, registry string, insecure bool, name, reference string, platform *Platform) (*Resolved, error) {
+	res, err := Get(ctx, registry, insecure, name, reference)
+	if err != nil {
+		return nil, err
+	}
 
-		if err := json.NewDecoder(res.Body).Decode(&m); err != nil {
-			return "", fmt.Errorf("decoding response: %w", err)
+	resolved := &Resolved{}
+
+	if res.IsIndex() {
+		idx, err := res.Index()
+		if err != nil {
+			return nil, err
 		}
 
-		if len(m.Manifests) == 0 {
-			return "", errors.New("no manifests found")
+		child, err := SelectManifest(idx, platform)
+		if err != nil {
+			return nil, err
 		}
 
-		return GetConfigDigestFromManifestList(ctx, registry, insecure, name, m.Manifests[0].Digest)
-	default:
-		log.Logger.Warn("Unexpected content type", zap.String("content-type", contentType))
+		resolved.Index = idx
+		resolved.IndexDigest = res.Digest
+
+		log.Logger.Debug("Following index child", zap.String("digest", child.Digest))
+
+		if res, err = GetByDescriptor(ctx, registry, insecure, name, child); err != nil {
+			return nil, fmt.Errorf("getting child manifest: %w", err)
+		}
+	}
+
+	if res.IsSchema1() {
+		log.Logger.Warn("Image uses the deprecated schema1 manifest format", zap.String("content-type", res.MediaType))
+
+		s1, err := res.Schema1()
+		if err != nil {
+			return nil, err
+		}
+
+		resolved.Schema1 = s1
+		resolved.Manifest = s1.Manifest()
+		resolved.Digest = res.Digest
+		resolved.MediaType = res.MediaType
+
+		return resolved, nil
+	}
+
+	if !res.IsManifest() {
+		log.Logger.Warn("Unexpected content type", zap.String("content-type", res.MediaType))
+		return nil, fmt.Errorf("unexpected content type %q", res.MediaType)
+	}
+
+	m, err := res.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	resolved.Manifest = m
+	resolved.Digest = res.Digest
+	resolved.MediaType = res.MediaType
+
+	return resolved, nil
+}
+
+// PutResult is the result of pushing a manifest
+type PutResult struct {
+	Digest string
+	// Subject is the digest of the subject the registry processed for the referrers
+	// API, empty when the registry does not support it.
+	Subject string
+}
+
+// Put pushes a manifest under the given reference, either a tag or its digest
+func Put(ctx context.Context, registry string, insecure bool, name, reference, mediaType string, body []byte) (*PutResult, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"PUT",
+		fmt.Sprintf("%s://%s/v2/%s/manifests/%s", http.ResolveProtocol(insecure), registry, name, reference),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", mediaType)
+
+	res, err := http.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("doing request: %w", err)
+	}
+	defer res.Body.Close() //nolint
+
+	if res.StatusCode != http.StatusCreated {
+		return nil, api.NewStatusError(res.StatusCode, res.Body)
+	}
+
+	digest := content.FromBytes(body)
+	if d := res.Header.Get("Docker-Content-Digest"); d != "" && d != digest {
+		return nil, &content.VerificationError{Field: "digest", Expected: digest, Actual: d}
 	}
 
-	return "", errors.New("unexpected content type")
+	return &PutResult{Digest: digest, Subject: res.Header.Get("OCI-Subject")}, nil
 }
@@ -2,14 +2,17 @@ package manifest
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/jcchavezs/nuro/internal/api"
+	"github.com/jcchavezs/nuro/internal/content"
 	"github.com/stretchr/testify/require"
 )
 
-func TestGetConfigDigestFromManifestSingle(t *testing.T) {
+func TestResolve(t *testing.T) {
 	tests := []struct {
 		name            string
 		nameParam       string
@@ -30,6 +33,26 @@ func TestGetConfigDigestFromManifestSingle(t *testing.T) {
 			expectedDigest:  "sha256:abc123",
 			expectErr:       false,
 		},
+		{
+			name:            "valid OCI manifest response",
+			nameParam:       "library/nginx",
+			reference:       "latest",
+			mockResponse:    `{"config": {"digest": "sha256:abc123"}}`,
+			mockStatusCode:  http.StatusOK,
+			mockContentType: OCIManifestV1ContentType,
+			expectedDigest:  "sha256:abc123",
+			expectErr:       false,
+		},
+		{
+			name:            "generic content type with media type in body",
+			nameParam:       "library/nginx",
+			reference:       "latest",
+			mockResponse:    `{"mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"digest": "sha256:abc123"}}`,
+			mockStatusCode:  http.StatusOK,
+			mockContentType: "application/json",
+			expectedDigest:  "sha256:abc123",
+			expectErr:       false,
+		},
 		{
 			name:            "unexpected content type",
 			nameParam:       "library/nginx",
@@ -77,15 +100,176 @@ func TestGetConfigDigestFromManifestSingle(t *testing.T) {
 			registry := server.URL[len("http://"):]
 
 			// Call the function
-			digest, err := GetConfigDigestFromManifestSingle(context.Background(), registry, true, tt.nameParam, tt.reference)
+			r, err := Resolve(context.Background(), registry, true, tt.nameParam, tt.reference, nil)
 
 			// Validate results
 			if tt.expectErr {
 				require.Error(t, err)
 			} else {
 				require.NoError(t, err)
-				require.Equal(t, tt.expectedDigest, digest)
+				require.Equal(t, tt.expectedDigest, r.Manifest.Config.Digest)
+			}
+		})
+	}
+}
+
+func TestResolveFollowsIndex(t *testing.T) {
+	child := `{"config": {"digest": "sha256:abc123"}}`
+	childDigest := content.FromBytes([]byte(child))
+
+	tests := []struct {
+		name           string
+		childSize      int
+		served         string
+		expectedDigest string
+		expectErr      bool
+	}{
+		{
+			name:           "valid child",
+			childSize:      len(child),
+			served:         child,
+			expectedDigest: "sha256:abc123",
+		},
+		{
+			name:      "child not matching the digest",
+			childSize: len(child),
+			served:    `{"config": {"digest": "sha256:def456"}}`,
+			expectErr: true,
+		},
+		{
+			name:      "child not matching the size",
+			childSize: len(child) - 1,
+			served:    child,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				switch r.URL.Path {
+				case "/v2/library/nginx/manifests/latest":
+					w.Header().Set("Content-Type", OCIIndexV1ContentType)
+					_, _ = fmt.Fprintf(w, `{"manifests": [{"digest": %q, "size": %d}]}`, childDigest, tt.childSize)
+				case "/v2/library/nginx/manifests/" + childDigest:
+					w.Header().Set("Content-Type", OCIManifestV1ContentType)
+					_, _ = w.Write([]byte(tt.served))
+				default:
+					t.Fatalf("unexpected path %s", r.URL.Path)
+				}
+			}))
+			defer server.Close()
+
+			r, err := Resolve(context.Background(), server.URL[len("http://"):], true, "library/nginx", "latest", nil)
+			require.Equal(t, 2, requests)
+			if tt.expectErr {
+				var vErr *content.VerificationError
+				require.ErrorAs(t, err, &vErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedDigest, r.Manifest.Config.Digest)
+				require.Equal(t, childDigest, r.Digest)
+			}
+		})
+	}
+}
+
+func TestResolveDoesNotRetry(t *testing.T) {
+	for _, statusCode := range []int{http.StatusNotFound, http.StatusUnauthorized} {
+		t.Run(http.StatusText(statusCode), func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(statusCode)
+			}))
+			defer server.Close()
+
+			_, err := Resolve(context.Background(), server.URL[len("http://"):], true, "library/nginx", "latest", nil)
+			require.Error(t, err)
+
+			var sErr *api.StatusError
+			require.ErrorAs(t, err, &sErr)
+			require.Equal(t, statusCode, sErr.StatusCode)
+			require.Equal(t, 1, requests)
+		})
+	}
+}
+
+func TestHead(t *testing.T) {
+	body := `{"config": {"digest": "sha256:abc123"}}`
+
+	t.Run("digest header", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodHead, r.Method)
+			w.Header().Set("Content-Type", manifestV2ContentType)
+			w.Header().Set("Docker-Content-Digest", "sha256:def456")
+		}))
+		defer server.Close()
+
+		d, err := Head(context.Background(), server.URL[len("http://"):], true, "library/nginx", "latest")
+		require.NoError(t, err)
+		require.Equal(t, "sha256:def456", d.Digest)
+		require.Equal(t, manifestV2ContentType, d.MediaType)
+	})
+
+	t.Run("missing digest header", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", manifestV2ContentType)
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(body))
 			}
+		}))
+		defer server.Close()
+
+		d, err := Head(context.Background(), server.URL[len("http://"):], true, "library/nginx", "latest")
+		require.NoError(t, err)
+		require.Equal(t, content.FromBytes([]byte(body)), d.Digest)
+		require.Equal(t, int64(len(body)), d.Size)
+	})
+}
+
+func TestManifestIsImage(t *testing.T) {
+	tests := []struct {
+		name            string
+		manifest        Manifest
+		expectedIsImage bool
+		expectedType    string
+	}{
+		{
+			name:            "OCI image",
+			manifest:        Manifest{Config: Descriptor{MediaType: OCIConfigV1ContentType}},
+			expectedIsImage: true,
+			expectedType:    OCIConfigV1ContentType,
+		},
+		{
+			name:            "docker image",
+			manifest:        Manifest{Config: Descriptor{MediaType: dockerConfigV1ContentType}},
+			expectedIsImage: true,
+			expectedType:    dockerConfigV1ContentType,
+		},
+		{
+			name:            "helm chart",
+			manifest:        Manifest{Config: Descriptor{MediaType: "application/vnd.cncf.helm.config.v1+json"}},
+			expectedIsImage: false,
+			expectedType:    "application/vnd.cncf.helm.config.v1+json",
+		},
+		{
+			name: "artifact with empty config",
+			manifest: Manifest{
+				ArtifactType: "application/vnd.example.sbom",
+				Config:       Descriptor{MediaType: "application/vnd.oci.empty.v1+json"},
+			},
+			expectedIsImage: false,
+			expectedType:    "application/vnd.example.sbom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedIsImage, tt.manifest.IsImage())
+			require.Equal(t, tt.expectedType, tt.manifest.Type())
 		})
 	}
 }
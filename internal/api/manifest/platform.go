@@ -0,0 +1,70 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParsePlatform parses a platform in the form os/arch[/variant]
+func ParsePlatform(s string) (*Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+	}
+
+	p := &Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+
+	if p.OS == "" || p.Architecture == "" {
+		return nil, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+	}
+
+	return p, nil
+}
+
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+
+	return s
+}
+
+// Matches returns true if the platform satisfies the wanted platform. The variant
+// is only compared when the wanted platform specifies one.
+func (p Platform) Matches(want Platform) bool {
+	if p.OS != want.OS || p.Architecture != want.Architecture {
+		return false
+	}
+
+	return want.Variant == "" || p.Variant == want.Variant
+}
+
+// ErrPlatformNotFound is returned when an index has no manifest for the wanted platform
+var ErrPlatformNotFound = errors.New("no manifest found for platform")
+
+// SelectManifest selects the descriptor for the given platform from the image manifests
+// in the index, attestation manifests are never selected. When platform is nil the first
+// image manifest is selected.
+func SelectManifest(idx *Index, platform *Platform) (Descriptor, error) {
+	images := idx.Images()
+	if len(images) == 0 {
+		return Descriptor{}, errors.New("no manifests found")
+	}
+
+	if platform == nil {
+		return images[0], nil
+	}
+
+	for _, m := range images {
+		if m.Platform != nil && m.Platform.Matches(*platform) {
+			return m, nil
+		}
+	}
+
+	return Descriptor{}, fmt.Errorf("%w %s", ErrPlatformNotFound, platform)
+}
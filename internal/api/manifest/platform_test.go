@@ -0,0 +1,83 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		name             string
+		platform         string
+		expectedPlatform *Platform
+		expectErr        bool
+	}{
+		{
+			name:             "os and architecture",
+			platform:         "linux/amd64",
+			expectedPlatform: &Platform{OS: "linux", Architecture: "amd64"},
+		},
+		{
+			name:             "os, architecture and variant",
+			platform:         "linux/arm64/v8",
+			expectedPlatform: &Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		},
+		{
+			name:      "missing architecture",
+			platform:  "linux",
+			expectErr: true,
+		},
+		{
+			name:      "empty os",
+			platform:  "/amd64",
+			expectErr: true,
+		},
+		{
+			name:      "too many parts",
+			platform:  "linux/arm/v7/extra",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePlatform(tt.platform)
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedPlatform, p)
+				require.Equal(t, tt.platform, p.String())
+			}
+		})
+	}
+}
+
+func TestSelectManifest(t *testing.T) {
+	idx := &Index{
+		Manifests: []Descriptor{
+			{Digest: "sha256:amd64", Platform: &Platform{OS: "linux", Architecture: "amd64"}},
+			{Digest: "sha256:armv7", Platform: &Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+			{Digest: "sha256:arm64", Platform: &Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+		},
+	}
+
+	d, err := SelectManifest(idx, nil)
+	require.NoError(t, err)
+	require.Equal(t, "sha256:amd64", d.Digest)
+
+	d, err = SelectManifest(idx, &Platform{OS: "linux", Architecture: "arm64"})
+	require.NoError(t, err)
+	require.Equal(t, "sha256:arm64", d.Digest)
+
+	d, err = SelectManifest(idx, &Platform{OS: "linux", Architecture: "arm", Variant: "v7"})
+	require.NoError(t, err)
+	require.Equal(t, "sha256:armv7", d.Digest)
+
+	_, err = SelectManifest(idx, &Platform{OS: "windows", Architecture: "amd64"})
+	require.ErrorIs(t, err, ErrPlatformNotFound)
+
+	_, err = SelectManifest(&Index{}, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,142 @@
+package manifest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	manifestV1ContentType       = "application/vnd.docker.distribution.manifest.v1+json"
+	signedManifestV1ContentType = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+
+	schema1LayerContentType = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// Schema1 is a docker image manifest v2 schema 1. The format is deprecated and only
+// supported for reading images from legacy registries.
+type Schema1 struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Name          string `json:"name"`
+	Tag           string `json:"tag"`
+	Architecture  string `json:"architecture"`
+	FSLayers      []struct {
+		BlobSum string `json:"blobSum"`
+	} `json:"fsLayers"`
+	// History contains the v1 compatibility config of every layer, the first entry
+	// being the top layer which holds the config of the image.
+	History []struct {
+		V1Compatibility string `json:"v1Compatibility"`
+	} `json:"history"`
+}
+
+// IsSchema1 returns true if the response is a deprecated schema1 manifest
+func (r *Response) IsSchema1() bool {
+	return r.MediaType == manifestV1ContentType || r.MediaType == signedManifestV1ContentType
+}
+
+// Schema1 decodes the response as a schema1 manifest
+func (r *Response) Schema1() (*Schema1, error) {
+	if !r.IsSchema1() {
+		return nil, fmt.Errorf("unexpected content type %q for schema1 manifest", r.MediaType)
+	}
+
+	m := &Schema1{}
+	if err := json.Unmarshal(r.Body, m); err != nil {
+		return nil, fmt.Errorf("decoding schema1 manifest: %w", err)
+	}
+
+	if len(m.History) == 0 {
+		return nil, errors.New("schema1 manifest has no history")
+	}
+
+	return m, nil
+}
+
+// Manifest converts the schema1 manifest into an image manifest with its layers in
+// bottom to top order. Schema1 manifests do not have a config blob nor layer sizes.
+// Throwaway layers, the empty ones created by metadata instructions, are left out as
+// in image manifests so the layers match the non empty history entries.
+func (s *Schema1) Manifest() *Manifest {
+	m := &Manifest{
+		SchemaVersion: 1,
+		Layers:        make([]Descriptor, 0, len(s.FSLayers)),
+	}
+
+	for i := len(s.FSLayers) - 1; i >= 0; i-- {
+		if i < len(s.History) {
+			var v1 struct {
+				Throwaway bool `json:"throwaway"`
+			}
+
+			if err := json.Unmarshal([]byte(s.History[i].V1Compatibility), &v1); err == nil && v1.Throwaway {
+				continue
+			}
+		}
+
+		m.Layers = append(m.Layers, Descriptor{
+			MediaType: schema1LayerContentType,
+			Digest:    s.FSLayers[i].BlobSum,
+		})
+	}
+
+	return m
+}
+
+// schema1Payload returns the payload of a signed schema1 manifest, which is the content
+// its digest is calculated from, by removing the signatures from it.
+func schema1Payload(body []byte) ([]byte, error) {
+	var jws struct {
+		Signatures []struct {
+			Protected string `json:"protected"`
+		} `json:"signatures"`
+	}
+
+	if err := json.Unmarshal(body, &jws); err != nil {
+		return nil, fmt.Errorf("decoding signed manifest: %w", err)
+	}
+
+	if len(jws.Signatures) == 0 {
+		return nil, errors.New("signed manifest has no signatures")
+	}
+
+	protected, err := base64.RawURLEncoding.DecodeString(jws.Signatures[0].Protected)
+	if err != nil {
+		return nil, fmt.Errorf("decoding protected header: %w", err)
+	}
+
+	var header struct {
+		FormatLength int    `json:"formatLength"`
+		FormatTail   string `json:"formatTail"`
+	}
+
+	if err := json.Unmarshal(protected, &header); err != nil {
+		return nil, fmt.Errorf("decoding protected header: %w", err)
+	}
+
+	tail, err := base64.RawURLEncoding.DecodeString(header.FormatTail)
+	if err != nil {
+		return nil, fmt.Errorf("decoding format tail: %w", err)
+	}
+
+	if header.FormatLength <= 0 || header.FormatLength > len(body) {
+		return nil, fmt.Errorf("invalid format length %d", header.FormatLength)
+	}
+
+	payload := make([]byte, 0, header.FormatLength+len(tail))
+	payload = append(payload, body[:header.FormatLength]...)
+	return append(payload, tail...), nil
+}
+
+// ErrPrinter prints to the error output, e.g. a cobra command
+type ErrPrinter interface {
+	PrintErrln(i ...any)
+}
+
+// WarnSchema1 warns through p when the resolved image uses the deprecated schema1 format
+func WarnSchema1(p ErrPrinter, r *Resolved) {
+	if r.Schema1 != nil {
+		p.PrintErrln("Warning: image uses the deprecated docker schema1 manifest format")
+	}
+}
@@ -0,0 +1,133 @@
+package manifest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/stretchr/testify/require"
+)
+
+const schema1Manifest = `{
+   "schemaVersion": 1,
+   "name": "library/nginx",
+   "tag": "latest",
+   "architecture": "amd64",
+   "fsLayers": [
+      {"blobSum": "sha256:top"},
+      {"blobSum": "sha256:bottom"}
+   ],
+   "history": [
+      {"v1Compatibility": "{\"created\":\"2016-01-01T00:00:00Z\"}"},
+      {"v1Compatibility": "{\"created\":\"2015-01-01T00:00:00Z\"}"}
+   ]
+}`
+
+// signSchema1 adds a signature to the schema1 manifest the same way docker did
+func signSchema1(payload string) string {
+	formatLength := len(payload) - 2
+	protected := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(
+		`{"formatLength":%d,"formatTail":%q}`,
+		formatLength,
+		base64.RawURLEncoding.EncodeToString([]byte(payload[formatLength:])),
+	)))
+
+	return payload[:formatLength] + `,
+   "signatures": [{"header": {"alg": "ES256"}, "signature": "c2ln", "protected": "` + protected + `"}]
+}`
+}
+
+func TestResolveSchema1(t *testing.T) {
+	payloadDigest := content.FromBytes([]byte(schema1Manifest))
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "unsigned manifest",
+			contentType: manifestV1ContentType,
+			body:        schema1Manifest,
+		},
+		{
+			name:        "signed manifest",
+			contentType: signedManifestV1ContentType,
+			body:        signSchema1(schema1Manifest),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("Docker-Content-Digest", payloadDigest)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			r, err := Resolve(context.Background(), server.URL[len("http://"):], true, "library/nginx", payloadDigest, nil)
+			require.NoError(t, err)
+			require.NotNil(t, r.Schema1)
+			require.Equal(t, payloadDigest, r.Digest)
+			require.Len(t, r.Schema1.History, 2)
+			require.Equal(t, []Descriptor{
+				{MediaType: schema1LayerContentType, Digest: "sha256:bottom"},
+				{MediaType: schema1LayerContentType, Digest: "sha256:top"},
+			}, r.Manifest.Layers)
+		})
+	}
+}
+
+func TestSchema1ManifestSkipsThrowawayLayers(t *testing.T) {
+	s := &Schema1{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"fsLayers": [{"blobSum": "sha256:top"}, {"blobSum": "sha256:empty"}, {"blobSum": "sha256:bottom"}],
+		"history": [
+			{"v1Compatibility": "{}"},
+			{"v1Compatibility": "{\"throwaway\":true}"},
+			{"v1Compatibility": "{}"}
+		]
+	}`), s))
+
+	require.Equal(t, []Descriptor{
+		{MediaType: schema1LayerContentType, Digest: "sha256:bottom"},
+		{MediaType: schema1LayerContentType, Digest: "sha256:top"},
+	}, s.Manifest().Layers)
+}
+
+func TestSchema1PayloadTampered(t *testing.T) {
+	signed := signSchema1(schema1Manifest)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", signedManifestV1ContentType)
+		w.Header().Set("Docker-Content-Digest", content.FromBytes([]byte(schema1Manifest)))
+		_, _ = w.Write([]byte(strings.Replace(signed, `"latest"`, `"tampered"`, 1)))
+	}))
+	defer server.Close()
+
+	_, err := Resolve(context.Background(), server.URL[len("http://"):], true, "library/nginx", "latest", nil)
+	var vErr *content.VerificationError
+	require.ErrorAs(t, err, &vErr)
+}
+
+type errPrinter []string
+
+func (p *errPrinter) PrintErrln(i ...any) {
+	*p = append(*p, fmt.Sprint(i...))
+}
+
+func TestWarnSchema1(t *testing.T) {
+	var p errPrinter
+	WarnSchema1(&p, &Resolved{Manifest: &Manifest{}})
+	require.Empty(t, p)
+
+	WarnSchema1(&p, &Resolved{Schema1: &Schema1{}})
+	require.Equal(t, errPrinter{"Warning: image uses the deprecated docker schema1 manifest format"}, p)
+}
@@ -0,0 +1,199 @@
+package referrers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jcchavezs/nuro/internal/api"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/cache"
+	"github.com/jcchavezs/nuro/internal/http"
+	"github.com/jcchavezs/nuro/internal/log"
+	"go.uber.org/zap"
+)
+
+// List lists the manifests referring to the manifest with the given digest, optionally
+// filtered by artifact type. When the registry does not support the referrers API it
+// falls back to the referrers tag schema.
+func List(ctx context.Context, registry string, insecure bool, name, digest, artifactType string) ([]manifest.Descriptor, error) {
+	descriptors, err := listFromAPI(ctx, registry, insecure, name, digest, artifactType)
+	if err == nil {
+		return descriptors, nil
+	}
+
+	if !api.IsNotFound(err) {
+		return nil, err
+	}
+
+	log.Logger.Debug("Referrers API not supported, falling back to tag schema", zap.String("digest", digest))
+
+	return listFromTagSchema(ctx, registry, insecure, name, digest, artifactType)
+}
+
+// TagSchemaTag returns the tag used to store the referrers of a digest in registries
+// without support for the referrers API, e.g. sha256-<hex>.
+func TagSchemaTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1)
+}
+
+// maxPages caps the number of referrers pages followed so a misbehaving registry cannot
+// keep the listing going forever
+const maxPages = 100
+
+func listFromAPI(ctx context.Context, registry string, insecure bool, name, digest, artifactType string) ([]manifest.Descriptor, error) {
+	u := fmt.Sprintf("%s://%s/v2/%s/referrers/%s", http.ResolveProtocol(insecure), registry, name, digest)
+	if artifactType != "" {
+		u += "?artifactType=" + url.QueryEscape(artifactType)
+	}
+
+	var descriptors []manifest.Descriptor
+	visited := map[string]bool{}
+	for u != "" {
+		if visited[u] {
+			return nil, fmt.Errorf("referrers pagination loops back to %s", u)
+		}
+
+		if len(visited) == maxPages {
+			return nil, fmt.Errorf("referrers span more than %d pages", maxPages)
+		}
+		visited[u] = true
+
+		req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+
+		req.Header.Set("Accept", manifest.OCIIndexV1ContentType)
+
+		page, next, filtered, err := doPage(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !filtered {
+			page = filterByArtifactType(page, artifactType)
+		}
+
+		descriptors = append(descriptors, page...)
+		u = next
+	}
+
+	return descriptors, nil
+}
+
+// doPage fetches a page of referrers returning the URL of the next page if any and
+// whether the registry already applied the artifact type filter.
+func doPage(req *http.Request) ([]manifest.Descriptor, string, bool, error) {
+	res, err := http.Client.Do(req)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("doing request: %w", err)
+	}
+	defer res.Body.Close() //nolint
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", false, api.NewStatusError(res.StatusCode, res.Body)
+	}
+
+	idx := manifest.Index{}
+	if err := json.NewDecoder(res.Body).Decode(&idx); err != nil {
+		return nil, "", false, fmt.Errorf("decoding response: %w", err)
+	}
+
+	filtered := strings.Contains(res.Header.Get("OCI-Filters-Applied"), "artifactType")
+
+	return idx.Manifests, nextPage(req.URL, res.Header.Get("Link")), filtered, nil
+}
+
+// nextPage returns the absolute URL of the next page from a Link header in the form
+// <url>; rel="next"
+func nextPage(base *url.URL, link string) string {
+	for _, l := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(l, ";")
+		if !ok || !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+			continue
+		}
+
+		target = strings.Trim(strings.TrimSpace(target), "<>")
+		next, err := base.Parse(target)
+		if err != nil {
+			log.Logger.Warn("Invalid link header", zap.String("link", link), zap.Error(err))
+			return ""
+		}
+
+		return next.String()
+	}
+
+	return ""
+}
+
+func listFromTagSchema(ctx context.Context, registry string, insecure bool, name, digest, artifactType string) ([]manifest.Descriptor, error) {
+	res, err := manifest.Get(ctx, registry, insecure, name, TagSchemaTag(digest))
+	if api.IsNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("getting referrers tag: %w", err)
+	}
+
+	idx, err := res.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	return filterByArtifactType(idx.Manifests, artifactType), nil
+}
+
+func filterByArtifactType(descriptors []manifest.Descriptor, artifactType string) []manifest.Descriptor {
+	if artifactType == "" {
+		return descriptors
+	}
+
+	filtered := make([]manifest.Descriptor, 0, len(descriptors))
+	for _, d := range descriptors {
+		if d.ArtifactType == artifactType {
+			filtered = append(filtered, d)
+		}
+	}
+
+	return filtered
+}
+
+// AddToTagSchema adds the descriptor to the referrers index stored under the tag schema
+// tag of the subject, for registries without support for the referrers API.
+func AddToTagSchema(ctx context.Context, registry string, insecure bool, name, subject string, d manifest.Descriptor) error {
+	idx := &manifest.Index{SchemaVersion: 2, MediaType: manifest.OCIIndexV1ContentType}
+
+	// the cached index could be stale and its referrers lost when pushing
+	res, err := manifest.Get(cache.WithoutCache(ctx), registry, insecure, name, TagSchemaTag(subject))
+	switch {
+	case err == nil:
+		if idx, err = res.Index(); err != nil {
+			return fmt.Errorf("getting referrers tag: %w", err)
+		}
+	case !api.IsNotFound(err):
+		return fmt.Errorf("getting referrers tag: %w", err)
+	}
+
+	for _, m := range idx.Manifests {
+		if m.Digest == d.Digest {
+			return nil
+		}
+	}
+
+	idx.Manifests = append(idx.Manifests, d)
+
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("encoding referrers index: %w", err)
+	}
+
+	if _, err := manifest.Put(ctx, registry, insecure, name, TagSchemaTag(subject), manifest.OCIIndexV1ContentType, b); err != nil {
+		return fmt.Errorf("pushing referrers tag: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package referrers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/stretchr/testify/require"
+)
+
+const digest = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+func TestListFromAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v2/library/nginx/referrers/"+digest, r.URL.Path)
+		w.Header().Set("Content-Type", manifest.OCIIndexV1ContentType)
+
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/library/nginx/referrers/`+digest+`?last=sbom>; rel="next"`)
+			_, _ = w.Write([]byte(`{"manifests": [
+				{"digest": "sha256:sbom", "artifactType": "application/spdx+json", "size": 10},
+				{"digest": "sha256:sig", "artifactType": "application/vnd.dev.cosign.artifact.sig.v1+json", "size": 20}
+			]}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"manifests": [{"digest": "sha256:sbom2", "artifactType": "application/spdx+json", "size": 30}]}`))
+	}))
+	defer server.Close()
+
+	registry := server.URL[len("http://"):]
+
+	descriptors, err := List(context.Background(), registry, true, "library/nginx", digest, "")
+	require.NoError(t, err)
+	require.Len(t, descriptors, 3)
+
+	descriptors, err = List(context.Background(), registry, true, "library/nginx", digest, "application/spdx+json")
+	require.NoError(t, err)
+	require.Equal(t, []manifest.Descriptor{
+		{Digest: "sha256:sbom", ArtifactType: "application/spdx+json", Size: 10},
+		{Digest: "sha256:sbom2", ArtifactType: "application/spdx+json", Size: 30},
+	}, descriptors)
+}
+
+func TestListFromAPIStopsPaginating(t *testing.T) {
+	for name, test := range map[string]struct {
+		link     func(r *http.Request) string
+		requests int
+		err      string
+	}{
+		"loop": {
+			link: func(r *http.Request) string {
+				return `</v2/library/nginx/referrers/` + digest + `>; rel="next"`
+			},
+			requests: 1,
+			err:      "loops back",
+		},
+		"endless": {
+			link: func(r *http.Request) string {
+				return `</v2/library/nginx/referrers/` + digest + `?last=` + r.URL.Query().Get("last") + `x>; rel="next"`
+			},
+			requests: maxPages,
+			err:      "more than 100 pages",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Header().Set("Content-Type", manifest.OCIIndexV1ContentType)
+				w.Header().Set("Link", test.link(r))
+				_, _ = w.Write([]byte(`{"manifests": []}`))
+			}))
+			defer server.Close()
+
+			_, err := listFromAPI(context.Background(), server.URL[len("http://"):], true, "library/nginx", digest, "")
+			require.ErrorContains(t, err, test.err)
+			require.Equal(t, test.requests, requests)
+		})
+	}
+}
+
+func TestListFallsBackToTagSchema(t *testing.T) {
+	tests := []struct {
+		name                string
+		tagExists           bool
+		expectedDescriptors int
+	}{
+		{
+			name:                "tag exists",
+			tagExists:           true,
+			expectedDescriptors: 1,
+		},
+		{
+			name:                "tag does not exist",
+			tagExists:           false,
+			expectedDescriptors: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.tagExists && r.URL.Path == "/v2/library/nginx/manifests/"+TagSchemaTag(digest) {
+					w.Header().Set("Content-Type", manifest.OCIIndexV1ContentType)
+					_, _ = w.Write([]byte(`{"manifests": [
+						{"digest": "sha256:sbom", "artifactType": "application/spdx+json"},
+						{"digest": "sha256:sig", "artifactType": "application/vnd.dev.cosign.artifact.sig.v1+json"}
+					]}`))
+					return
+				}
+
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			defer server.Close()
+
+			descriptors, err := List(context.Background(), server.URL[len("http://"):], true, "library/nginx", digest, "application/spdx+json")
+			require.NoError(t, err)
+			require.Len(t, descriptors, tt.expectedDescriptors)
+		})
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	base, _ := url.Parse("https://registry.example.com/v2/library/nginx/referrers/sha256:abc")
+
+	require.Equal(t, "https://registry.example.com/v2/library/nginx/referrers/sha256:abc?last=x", nextPage(base, `</v2/library/nginx/referrers/sha256:abc?last=x>; rel="next"`))
+	require.Equal(t, "https://other.example.com/page2", nextPage(base, `<https://other.example.com/page2>; rel="next"`))
+	require.Equal(t, "", nextPage(base, `</page0>; rel="prev"`))
+	require.Equal(t, "", nextPage(base, ""))
+}
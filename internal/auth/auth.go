@@ -13,6 +13,8 @@ import (
 type ImageMetadata struct {
 	Registry string
 	Name     string
+	// Push requests credentials allowing to push to the repository
+	Push bool
 }
 
 type imageMetadataKey struct{}
@@ -42,9 +44,18 @@ type authRoundTripper struct {
 }
 
 func (rt authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	if metadata, ok := req.Context().Value(ctxKey).(ImageMetadata); ok {
+	// credentials are only sent to the registry itself and not to the hosts it redirects
+	// to, e.g. the object storage serving the blobs.
+	if metadata, ok := req.Context().Value(ctxKey).(ImageMetadata); ok && req.URL.Host == metadata.Registry {
 		if metadata.Registry == image.DockerRegistry {
-			if token, err := docker.GetToken(req.Context(), metadata.Name); err != nil {
+			var username, password string
+			if netRC != nil {
+				if m := netRC.Machine("docker.io"); m != nil {
+					username, password = m.Get("login"), m.Get("password")
+				}
+			}
+
+			if token, err := docker.GetToken(req.Context(), metadata.Name, metadata.Push, username, password); err != nil {
 				return nil, fmt.Errorf("authenticating in docker registry: %w", err)
 			} else {
 				req.Header.Set("Authorization", "Bearer "+token)
@@ -5,16 +5,41 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sync"
 )
 
-var cachedToken string
+var (
+	cachedTokens   = map[string]string{}
+	cachedTokensMu sync.Mutex
+)
+
+// GetToken gets a token for the image from the docker registry, requesting push access
+// when push is true. Credentials are only sent when a username is provided, anonymous
+// tokens only allow pulling public images.
+func GetToken(ctx context.Context, image string, push bool, username, password string) (string, error) {
+	scope := "repository:" + image + ":pull"
+	if push {
+		scope += ",push"
+	}
+
+	cachedTokensMu.Lock()
+	defer cachedTokensMu.Unlock()
+
+	if token, ok := cachedTokens[scope]; ok {
+		return token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://auth.docker.io/token?service=registry.docker.io&scope="+url.QueryEscape(scope), nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
 
-func GetToken(ctx context.Context, image string) (string, error) {
-	if cachedToken != "" {
-		return cachedToken, nil
+	if username != "" {
+		req.SetBasicAuth(username, password)
 	}
 
-	resp, err := http.DefaultClient.Get("https://auth.docker.io/token?service=registry.docker.io&scope=repository:" + image + ":pull")
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("doing request: %w", err)
 	}
@@ -32,6 +57,6 @@ func GetToken(ctx context.Context, image string) (string, error) {
 		return "", fmt.Errorf("decoding response: %w", err)
 	}
 
-	cachedToken = result.Token
-	return cachedToken, nil
+	cachedTokens[scope] = result.Token
+	return result.Token, nil
 }
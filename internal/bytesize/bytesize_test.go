@@ -0,0 +1,41 @@
+package bytesize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		size      string
+		expected  int64
+		expectErr bool
+	}{
+		{size: "1024", expected: 1024},
+		{size: "500MB", expected: 500_000_000},
+		{size: "2GiB", expected: 2 << 30},
+		{size: "1.5 kib", expected: 1536},
+		{size: "10B", expected: 10},
+		{size: "lots", expectErr: true},
+		{size: "-1MB", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			n, err := Parse(tt.size)
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, n)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	require.Equal(t, "512 B", Format(512))
+	require.Equal(t, "1.5 KiB", Format(1536))
+	require.Equal(t, "2.0 GiB", Format(2<<30))
+}
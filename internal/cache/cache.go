@@ -0,0 +1,441 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/jcchavezs/nuro/internal/log"
+	"go.uber.org/zap"
+)
+
+// ErrOffline is returned when a request cannot be served from the cache in offline mode
+var ErrOffline = errors.New("not available in the cache and offline mode is enabled")
+
+const (
+	manifestsDir = "manifests"
+	blobsDir     = "blobs"
+	tagsDir      = "tags"
+
+	mediaTypeExt = ".mediatype"
+)
+
+// DefaultTTL is for how long tag resolutions are served from the cache by default
+const DefaultTTL = 10 * time.Minute
+
+// DefaultMaxSize is the size the cache is pruned to by default
+const DefaultMaxSize = "1GiB"
+
+var (
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+	offline bool
+
+	// size is the total size of the cached objects, -1 until it is computed
+	size   int64 = -1
+	sizeMu sync.Mutex
+)
+
+// DefaultDir returns the cache directory, that is $XDG_CACHE_HOME/nuro or the platform
+// equivalent.
+func DefaultDir() (string, error) {
+	d, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving cache directory: %w", err)
+	}
+
+	return filepath.Join(d, "nuro"), nil
+}
+
+// Init enables the cache in the given directory. Tag resolutions are trusted for the
+// TTL, in offline mode every request is served from the cache regardless of the TTL. The
+// least recently used content is pruned whenever the cache grows over maxSize bytes, a
+// zero maxSize disables the limit.
+func Init(cacheDir string, tagTTL time.Duration, maxSizeBytes int64, offlineMode bool) {
+	dir, ttl, maxSize, offline = cacheDir, tagTTL, maxSizeBytes, offlineMode
+
+	sizeMu.Lock()
+	size = -1
+	sizeMu.Unlock()
+}
+
+func enabled() bool {
+	return dir != ""
+}
+
+type noCacheKey struct{}
+
+// WithoutCache returns a context whose requests are never served from the cache, e.g. the
+// reads preceding a write which need the current content of a tag. Responses are still
+// cached.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+func bypassed(ctx context.Context) bool {
+	b, _ := ctx.Value(noCacheKey{}).(bool)
+	return b
+}
+
+// maxBlobFraction limits the size of a cached blob to a fraction of the maximum size so
+// a single large layer does not evict the rest of the cache
+const maxBlobFraction = 4
+
+// fits tells whether a blob of n bytes is small enough to be cached
+func fits(n int64) bool {
+	return maxSize <= 0 || n <= maxSize/maxBlobFraction
+}
+
+// track accounts for a newly stored object and prunes the cache when it grows over the
+// maximum size
+func track(n int64) {
+	if maxSize <= 0 {
+		return
+	}
+
+	sizeMu.Lock()
+	defer sizeMu.Unlock()
+
+	if size < 0 {
+		// the first computation already includes the new object
+		entries, err := List(dir)
+		if err != nil {
+			log.Logger.Debug("Failed to compute the cache size", zap.Error(err))
+			return
+		}
+
+		size = 0
+		for _, e := range entries {
+			if e.Kind != KindTag {
+				size += e.Size
+			}
+		}
+	} else {
+		size += n
+	}
+
+	if size <= maxSize {
+		return
+	}
+
+	res, err := Prune(dir, maxSize, ttl)
+	if err != nil {
+		log.Logger.Debug("Failed to prune the cache", zap.Error(err))
+	} else {
+		log.Logger.Debug("Pruned the cache", zap.Int("removed", res.Removed), zap.Int64("freed", res.Freed))
+	}
+
+	// other processes may be writing to the cache too so it is computed again
+	size = -1
+}
+
+// Kind is the kind of a cache entry
+type Kind string
+
+const (
+	KindManifest Kind = "manifest"
+	KindBlob     Kind = "blob"
+	KindTag      Kind = "tag"
+)
+
+// Entry is a file in the cache
+type Entry struct {
+	Kind Kind `json:"kind"`
+	// Reference is the digest for manifests and blobs and registry/name:tag for tags
+	Reference string    `json:"reference"`
+	Size      int64     `json:"size"`
+	LastUsed  time.Time `json:"lastUsed"`
+	// Digest is the digest a tag resolves to
+	Digest string `json:"digest,omitempty"`
+
+	path string
+}
+
+type tagRecord struct {
+	Digest     string    `json:"digest"`
+	ResolvedAt time.Time `json:"resolvedAt"`
+}
+
+func objectPath(base string, kind Kind, digest string) (string, error) {
+	algorithm, hex, err := content.ParseDigest(digest)
+	if err != nil {
+		return "", err
+	}
+
+	sub := blobsDir
+	if kind == KindManifest {
+		sub = manifestsDir
+	}
+
+	return filepath.Join(base, sub, algorithm, hex), nil
+}
+
+// tagPath returns the path of the tag record. Colons in the registry are replaced as they
+// are not valid in file names on every platform.
+func tagPath(base, registry, name, tag string) string {
+	return filepath.Join(base, tagsDir, strings.ReplaceAll(registry, ":", "_"), filepath.FromSlash(name), tag+".json")
+}
+
+// open opens a cached object and marks it as used
+func open(kind Kind, digest string) (*os.File, string, error) {
+	p, err := objectPath(dir, kind, digest)
+	if err != nil {
+		return nil, "", err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+
+	var mediaType string
+	if kind == KindManifest {
+		b, err := os.ReadFile(p + mediaTypeExt)
+		if err != nil {
+			_ = f.Close()
+			return nil, "", err
+		}
+
+		mediaType = string(b)
+	}
+
+	return f, mediaType, nil
+}
+
+// resolveTag returns the digest a tag resolved to if the resolution did not expire
+func resolveTag(registry, name, tag string) (string, bool) {
+	b, err := os.ReadFile(tagPath(dir, registry, name, tag))
+	if err != nil {
+		return "", false
+	}
+
+	var r tagRecord
+	if err := json.Unmarshal(b, &r); err != nil {
+		return "", false
+	}
+
+	if !offline && time.Since(r.ResolvedAt) > ttl {
+		return "", false
+	}
+
+	return r.Digest, true
+}
+
+func storeTag(registry, name, tag, digest string) error {
+	b, err := json.Marshal(tagRecord{Digest: digest, ResolvedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	return writeFile(tagPath(dir, registry, name, tag), b)
+}
+
+// writeFile writes the file atomically so concurrent readers never see partial content
+func writeFile(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// List lists the entries in the cache directory sorted by kind and reference
+func List(base string) ([]Entry, error) {
+	var entries []Entry
+
+	for _, kind := range []Kind{KindManifest, KindBlob} {
+		sub := blobsDir
+		if kind == KindManifest {
+			sub = manifestsDir
+		}
+
+		err := walk(filepath.Join(base, sub), func(path string, info fs.FileInfo) error {
+			if strings.HasSuffix(path, mediaTypeExt) || strings.HasPrefix(info.Name(), ".tmp-") {
+				return nil
+			}
+
+			rel, _ := filepath.Rel(filepath.Join(base, sub), path)
+			algorithm, hex, _ := strings.Cut(filepath.ToSlash(rel), "/")
+			entries = append(entries, Entry{
+				Kind:      kind,
+				Reference: algorithm + ":" + hex,
+				Size:      info.Size(),
+				LastUsed:  info.ModTime(),
+				path:      path,
+			})
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := walk(filepath.Join(base, tagsDir), func(path string, info fs.FileInfo) error {
+		if !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var r tagRecord
+		if err := json.Unmarshal(b, &r); err != nil {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(filepath.Join(base, tagsDir), strings.TrimSuffix(path, ".json"))
+		repo, tag := filepath.Split(filepath.ToSlash(rel))
+		registry, name, _ := strings.Cut(strings.TrimSuffix(repo, "/"), "/")
+		entries = append(entries, Entry{
+			Kind:      KindTag,
+			Reference: strings.ReplaceAll(registry, "_", ":") + "/" + name + ":" + tag,
+			Size:      info.Size(),
+			LastUsed:  r.ResolvedAt,
+			Digest:    r.Digest,
+			path:      path,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		return entries[i].Reference < entries[j].Reference
+	})
+
+	return entries, nil
+}
+
+func walk(root string, fn func(path string, info fs.FileInfo) error) error {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		return fn(path, info)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// PruneResult summarizes what a prune removed
+type PruneResult struct {
+	Removed int
+	Freed   int64
+}
+
+// Prune removes the tag resolutions older than the TTL and then the least recently
+// used manifests and blobs until the cache fits in maxSize bytes. A zero maxSize only
+// removes the expired tag resolutions.
+func Prune(base string, maxSize int64, tagTTL time.Duration) (PruneResult, error) {
+	entries, err := List(base)
+	if err != nil {
+		return PruneResult{}, err
+	}
+
+	var (
+		res     PruneResult
+		objects []Entry
+		total   int64
+	)
+
+	for _, e := range entries {
+		if e.Kind == KindTag {
+			if time.Since(e.LastUsed) > tagTTL {
+				if err := remove(e, &res); err != nil {
+					return res, err
+				}
+			}
+			continue
+		}
+
+		objects = append(objects, e)
+		total += e.Size
+	}
+
+	if maxSize <= 0 {
+		return res, nil
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].LastUsed.Before(objects[j].LastUsed) })
+	for _, e := range objects {
+		if total <= maxSize {
+			break
+		}
+
+		if err := remove(e, &res); err != nil {
+			return res, err
+		}
+		total -= e.Size
+	}
+
+	return res, nil
+}
+
+func remove(e Entry, res *PruneResult) error {
+	if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing %s: %w", e.Reference, err)
+	}
+
+	if e.Kind == KindManifest {
+		_ = os.Remove(e.path + mediaTypeExt)
+	}
+
+	res.Removed++
+	res.Freed += e.Size
+	return nil
+}
+
+// Clear removes the cache directory
+func Clear(base string) error {
+	return os.RemoveAll(base)
+}
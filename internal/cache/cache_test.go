@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/stretchr/testify/require"
+)
+
+const manifestBody = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`
+
+var manifestDigest = content.FromBytes([]byte(manifestBody))
+
+type testRegistry struct {
+	*httptest.Server
+	requests int
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	reg := &testRegistry{}
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reg.requests++
+		_, _ = w.Write([]byte("blob"))
+	}))
+	t.Cleanup(storage.Close)
+
+	reg.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reg.requests++
+		switch r.URL.Path {
+		case "/v2/app/manifests/latest", "/v2/app/manifests/" + manifestDigest:
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", manifestDigest)
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(manifestBody))
+			}
+		case "/v2/app/manifests/tampered":
+			w.Header().Set("Docker-Content-Digest", manifestDigest)
+			_, _ = w.Write([]byte(`{"tampered":true}`))
+		case "/v2/app/blobs/" + content.FromBytes([]byte("blob")):
+			http.Redirect(w, r, storage.URL+"/bucket/blob", http.StatusTemporaryRedirect)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(reg.Server.Close)
+
+	return reg
+}
+
+func (reg *testRegistry) get(t *testing.T, method, path string) (*http.Response, []byte, error) {
+	return reg.getWithContext(t, context.Background(), method, path)
+}
+
+func (reg *testRegistry) getWithContext(t *testing.T, ctx context.Context, method, path string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, reg.URL+path, nil)
+	require.NoError(t, err)
+
+	res, err := (&http.Client{Transport: WrapRoundTripper(http.DefaultTransport)}).Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close() //nolint
+
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	return res, b, nil
+}
+
+func initTest(t *testing.T, tagTTL time.Duration) string {
+	d := t.TempDir()
+	Init(d, tagTTL, 0, false)
+	t.Cleanup(func() { Init("", 0, 0, false) })
+
+	return d
+}
+
+func TestCachesManifestsByTagAndDigest(t *testing.T) {
+	initTest(t, time.Hour)
+	reg := newTestRegistry(t)
+
+	_, b, err := reg.get(t, http.MethodGet, "/v2/app/manifests/latest")
+	require.NoError(t, err)
+	require.Equal(t, manifestBody, string(b))
+	require.Equal(t, 1, reg.requests)
+
+	for _, path := range []string{"/v2/app/manifests/latest", "/v2/app/manifests/" + manifestDigest} {
+		res, b, err := reg.get(t, http.MethodGet, path)
+		require.NoError(t, err)
+		require.Equal(t, manifestBody, string(b))
+		require.Equal(t, "application/vnd.oci.image.manifest.v1+json", res.Header.Get("Content-Type"))
+		require.Equal(t, manifestDigest, res.Header.Get("Docker-Content-Digest"))
+
+		res, _, err = reg.get(t, http.MethodHead, path)
+		require.NoError(t, err)
+		require.Equal(t, manifestDigest, res.Header.Get("Docker-Content-Digest"))
+	}
+	require.Equal(t, 1, reg.requests)
+
+	Init(dir, ttl, maxSize, true)
+	_, b, err = reg.get(t, http.MethodGet, "/v2/app/manifests/latest")
+	require.NoError(t, err)
+	require.Equal(t, manifestBody, string(b))
+
+	_, _, err = reg.get(t, http.MethodGet, "/v2/app/manifests/other")
+	require.True(t, errors.Is(err, ErrOffline))
+}
+
+func TestWithoutCacheReachesTheRegistry(t *testing.T) {
+	initTest(t, time.Hour)
+	reg := newTestRegistry(t)
+
+	_, _, err := reg.get(t, http.MethodGet, "/v2/app/manifests/latest")
+	require.NoError(t, err)
+	require.Equal(t, 1, reg.requests)
+
+	ctx := WithoutCache(context.Background())
+	for _, path := range []string{"/v2/app/manifests/latest", "/v2/app/manifests/" + manifestDigest} {
+		_, b, err := reg.getWithContext(t, ctx, http.MethodGet, path)
+		require.NoError(t, err)
+		require.Equal(t, manifestBody, string(b))
+	}
+	require.Equal(t, 3, reg.requests)
+
+	_, _, err = reg.get(t, http.MethodGet, "/v2/app/manifests/latest")
+	require.NoError(t, err)
+	require.Equal(t, 3, reg.requests)
+}
+
+func TestExpiredTagIsResolvedAgain(t *testing.T) {
+	initTest(t, 0)
+	reg := newTestRegistry(t)
+
+	for i := 0; i < 2; i++ {
+		_, _, err := reg.get(t, http.MethodGet, "/v2/app/manifests/latest")
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, reg.requests)
+
+	// the expired resolution is still used offline
+	Init(dir, ttl, maxSize, true)
+	_, _, err := reg.get(t, http.MethodGet, "/v2/app/manifests/latest")
+	require.NoError(t, err)
+}
+
+func TestContentNotMatchingDigestIsNotCached(t *testing.T) {
+	d := initTest(t, time.Hour)
+	reg := newTestRegistry(t)
+
+	_, _, err := reg.get(t, http.MethodGet, "/v2/app/manifests/tampered")
+	require.NoError(t, err)
+
+	entries, err := List(d)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestCachesRedirectedBlobs(t *testing.T) {
+	d := initTest(t, time.Hour)
+	reg := newTestRegistry(t)
+
+	path := "/v2/app/blobs/" + content.FromBytes([]byte("blob"))
+	for i := 0; i < 2; i++ {
+		_, b, err := reg.get(t, http.MethodGet, path)
+		require.NoError(t, err)
+		require.Equal(t, "blob", string(b))
+	}
+	require.Equal(t, 2, reg.requests)
+
+	entries, err := List(d)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, KindBlob, entries[0].Kind)
+}
+
+func TestMaxSizeIsEnforcedOnWrite(t *testing.T) {
+	d := t.TempDir()
+	Init(d, time.Hour, int64(len(manifestBody)), false)
+	t.Cleanup(func() { Init("", 0, 0, false) })
+
+	reg := newTestRegistry(t)
+
+	blobDigest := content.FromBytes([]byte("blob"))
+	_, _, err := reg.get(t, http.MethodGet, "/v2/app/blobs/"+blobDigest)
+	require.NoError(t, err)
+
+	p, err := objectPath(d, KindBlob, blobDigest)
+	require.NoError(t, err)
+	require.NoError(t, os.Chtimes(p, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+
+	// the manifest does not fit along with the blob, which is the least recently used
+	_, _, err = reg.get(t, http.MethodGet, "/v2/app/manifests/"+manifestDigest)
+	require.NoError(t, err)
+
+	entries, err := List(d)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, manifestDigest, entries[0].Reference)
+}
+
+func TestLargeBlobsAreNotCached(t *testing.T) {
+	d := t.TempDir()
+	Init(d, time.Hour, int64(len("blob"))*maxBlobFraction-1, false)
+	t.Cleanup(func() { Init("", 0, 0, false) })
+
+	reg := newTestRegistry(t)
+
+	blobDigest := content.FromBytes([]byte("blob"))
+	for i := 0; i < 2; i++ {
+		_, b, err := reg.get(t, http.MethodGet, "/v2/app/blobs/"+blobDigest)
+		require.NoError(t, err)
+		require.Equal(t, "blob", string(b))
+	}
+	// each request is redirected to the storage
+	require.Equal(t, 4, reg.requests)
+
+	entries, err := List(d)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestPrune(t *testing.T) {
+	d := t.TempDir()
+
+	old, err := objectPath(d, KindBlob, content.FromBytes([]byte("old")))
+	require.NoError(t, err)
+	require.NoError(t, writeFile(old, []byte("old")))
+	require.NoError(t, os.Chtimes(old, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+
+	recent, err := objectPath(d, KindBlob, content.FromBytes([]byte("recent")))
+	require.NoError(t, err)
+	require.NoError(t, writeFile(recent, []byte("recent")))
+
+	require.NoError(t, writeFile(tagPath(d, "registry.example.com:5000", "team/app", "v1"), []byte(`{"digest":"sha256:abc","resolvedAt":"2020-01-01T00:00:00Z"}`)))
+
+	entries, err := List(d)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, "registry.example.com:5000/team/app:v1", entries[2].Reference)
+
+	res, err := Prune(d, 0, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Removed)
+
+	res, err = Prune(d, 6, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Removed)
+
+	entries, err = List(d)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, content.FromBytes([]byte("recent")), entries[0].Reference)
+}
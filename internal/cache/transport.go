@@ -0,0 +1,309 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/jcchavezs/nuro/internal/log"
+	"go.uber.org/zap"
+)
+
+var cacheablePath = regexp.MustCompile(`^/v2/(.+)/(manifests|blobs)/([^/]+)$`)
+
+type cacheRoundTripper struct {
+	http.RoundTripper
+}
+
+// RoundTrip serves manifests and blobs from the cache when possible and stores the
+// verified responses otherwise. Only whole GET and HEAD responses are cached, manifests
+// requested by tag are cached by the digest they resolve to and blobs over a fraction
+// of the maximum size are not cached. Requests with a context from WithoutCache always
+// reach the registry.
+func (rt cacheRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !enabled() {
+		return rt.RoundTripper.RoundTrip(req)
+	}
+
+	// blobs are usually redirected to an object storage, in which case the response is
+	// cached for the original request
+	origin := req
+	for origin.Response != nil && origin.Response.Request != nil {
+		origin = origin.Response.Request
+	}
+	host := origin.URL.Host
+
+	m := cacheablePath.FindStringSubmatch(origin.URL.Path)
+	if m == nil || (req.Method != http.MethodGet && req.Method != http.MethodHead) || req.Header.Get("Range") != "" {
+		if offline {
+			return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, ErrOffline)
+		}
+
+		res, err := rt.RoundTripper.RoundTrip(req)
+		if err == nil && m != nil && m[2] == "manifests" && req.Method == http.MethodPut && !content.IsDigest(m[3]) {
+			// the tag was moved so its cached resolution is stale
+			_ = os.Remove(tagPath(dir, host, m[1], m[3]))
+		}
+
+		return res, err
+	}
+
+	name, reference := m[1], m[3]
+	kind := KindBlob
+	if m[2] == "manifests" {
+		kind = KindManifest
+	}
+
+	digest, isTag := reference, !content.IsDigest(reference)
+	if isTag {
+		digest = ""
+		if kind == KindManifest {
+			digest, _ = resolveTag(host, name, reference)
+		}
+	}
+
+	if digest != "" && !bypassed(req.Context()) {
+		if res, ok := serve(req, kind, digest); ok {
+			log.Logger.Debug("Serving from cache", zap.String("url", req.URL.String()), zap.String("digest", digest))
+			return res, nil
+		}
+	}
+
+	if offline {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, ErrOffline)
+	}
+
+	res, err := rt.RoundTripper.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		return res, err
+	}
+
+	expected := res.Header.Get("Docker-Content-Digest")
+	if !isTag {
+		expected = reference
+	}
+
+	if req.Method == http.MethodHead {
+		if isTag && kind == KindManifest && expected != "" {
+			if err := storeTag(host, name, reference, expected); err != nil {
+				log.Logger.Debug("Failed to cache tag", zap.Error(err))
+			}
+		}
+
+		return res, nil
+	}
+
+	if kind == KindBlob && !fits(res.ContentLength) {
+		log.Logger.Debug("Blob too large to be cached", zap.String("url", req.URL.String()), zap.Int64("size", res.ContentLength))
+		return res, nil
+	}
+
+	sr, err := newStoringReader(res.Body, kind, expected, func(actual string) error {
+		if isTag && kind == KindManifest {
+			return storeTag(host, name, reference, actual)
+		}
+
+		return nil
+	}, res.Header.Get("Content-Type"))
+	if err != nil {
+		log.Logger.Debug("Failed to cache response", zap.Error(err))
+		return res, nil
+	}
+
+	res.Body = sr
+	return res, nil
+}
+
+// serve builds a response out of the cached object
+func serve(req *http.Request, kind Kind, digest string) (*http.Response, bool) {
+	f, mediaType, err := open(kind, digest)
+	if err != nil {
+		return nil, false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, false
+	}
+
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+
+	res := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Type":          {mediaType},
+			"Content-Length":        {strconv.FormatInt(info.Size(), 10)},
+			"Docker-Content-Digest": {digest},
+		},
+		ContentLength: info.Size(),
+		Body:          f,
+		Request:       req,
+	}
+
+	if req.Method == http.MethodHead {
+		_ = f.Close()
+		res.Body = http.NoBody
+	}
+
+	return res, true
+}
+
+// storingReader copies the body into a temporary file while it is read and moves it
+// into the cache once it is read until EOF and matches the expected digest. Failures
+// storing the content never fail the read.
+type storingReader struct {
+	body      io.ReadCloser
+	tmp       *os.File
+	hash      hash.Hash
+	algorithm string
+	kind      Kind
+	expected  string
+	mediaType string
+	onStore   func(digest string) error
+	size      int64
+	done      bool
+}
+
+func newStoringReader(body io.ReadCloser, kind Kind, expected string, onStore func(string) error, mediaType string) (*storingReader, error) {
+	algorithm := "sha256"
+	if expected != "" {
+		var err error
+		if algorithm, _, err = content.ParseDigest(expected); err != nil {
+			return nil, err
+		}
+	}
+
+	// the temporary file lives next to its destination so it can be renamed into place
+	tmpDir := filepath.Join(dir, blobsDir, algorithm)
+	if kind == KindManifest {
+		tmpDir = filepath.Join(dir, manifestsDir, algorithm)
+	}
+
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(tmpDir, ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	if algorithm == "sha512" {
+		h = sha512.New()
+	}
+
+	return &storingReader{
+		body:      body,
+		tmp:       tmp,
+		hash:      h,
+		algorithm: algorithm,
+		kind:      kind,
+		expected:  expected,
+		mediaType: mediaType,
+		onStore:   onStore,
+	}, nil
+}
+
+func (sr *storingReader) Read(p []byte) (int, error) {
+	n, err := sr.body.Read(p)
+	if n > 0 && sr.tmp != nil {
+		sr.hash.Write(p[:n])
+		sr.size += int64(n)
+		if sr.kind == KindBlob && !fits(sr.size) {
+			// the length was not known upfront
+			sr.abandon()
+		} else if _, werr := sr.tmp.Write(p[:n]); werr != nil {
+			sr.abandon()
+		}
+	}
+
+	if err == io.EOF && !sr.done {
+		sr.done = true
+		if serr := sr.store(); serr != nil {
+			log.Logger.Debug("Failed to cache response", zap.Error(serr))
+		}
+	}
+
+	return n, err
+}
+
+func (sr *storingReader) store() error {
+	if sr.tmp == nil {
+		return nil
+	}
+
+	tmp := sr.tmp
+	sr.tmp = nil
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	actual := sr.algorithm + ":" + hex.EncodeToString(sr.hash.Sum(nil))
+	if sr.expected != "" && actual != sr.expected {
+		// e.g. signed schema1 manifests whose digest is computed over the payload only
+		_ = os.Remove(tmp.Name())
+		return nil
+	}
+
+	p, err := objectPath(dir, sr.kind, actual)
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	// the media type is written first so a cached manifest is never served without it
+	if sr.kind == KindManifest {
+		if err := writeFile(p+mediaTypeExt, []byte(sr.mediaType)); err != nil {
+			_ = os.Remove(tmp.Name())
+			return err
+		}
+	}
+
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := sr.onStore(actual); err != nil {
+		return err
+	}
+
+	track(sr.size)
+	return nil
+}
+
+func (sr *storingReader) abandon() {
+	if sr.tmp != nil {
+		_ = sr.tmp.Close()
+		_ = os.Remove(sr.tmp.Name())
+		sr.tmp = nil
+	}
+}
+
+func (sr *storingReader) Close() error {
+	sr.abandon()
+	return sr.body.Close()
+}
+
+func WrapRoundTripper(t http.RoundTripper) http.RoundTripper {
+	return cacheRoundTripper{t}
+}
@@ -0,0 +1,190 @@
+package artifact
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/jedib0t/go-pretty/text"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+)
+
+var Formats = map[OutputFormat][]string{
+	Table: {"table"},
+	JSON:  {"json"},
+}
+
+type OutputFormat int
+
+const (
+	Table OutputFormat = iota
+	JSON
+)
+
+var outputFormat OutputFormat = Table
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&outputFormat, "string", Formats, enumflag.EnumCaseInsensitive),
+		"output",
+		"Sets the output format",
+	)
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "artifact <ref>",
+	Short:   "Describes an OCI artifact, e.g. a helm chart or a WASM module",
+	Example: "$ nuro artifact ghcr.io/org/charts/app:1.0.0",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		m, err := manifest.Resolve(ctx, registry, insecure, name, reference, nil)
+		if err != nil {
+			return fmt.Errorf("resolving manifest: %w", err)
+		}
+
+		s := summarize(image.FormatReference(registry, name, tag, digest), m)
+
+		switch outputFormat {
+		case JSON:
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(s); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		default:
+			printSummary(cmd.OutOrStdout(), s)
+		}
+
+		return nil
+	},
+}
+
+const titleAnnotation = "org.opencontainers.image.title"
+
+// knownArtifactTypes are friendly names for well known artifact types
+var knownArtifactTypes = map[string]string{
+	"application/vnd.oci.image.config.v1+json":          "Container image",
+	"application/vnd.docker.container.image.v1+json":    "Container image",
+	"application/vnd.cncf.helm.config.v1+json":          "Helm chart",
+	"application/vnd.wasm.config.v0+json":               "WebAssembly module",
+	"application/vnd.module.wasm.content.layer.v1+wasm": "WebAssembly module",
+	"application/vnd.dev.cosign.artifact.sig.v1+json":   "Cosign signature",
+	"application/vnd.cncf.notary.signature":             "Notation signature",
+	"application/spdx+json":                             "SPDX SBOM",
+	"application/vnd.cyclonedx+json":                    "CycloneDX SBOM",
+	"application/vnd.in-toto+json":                      "In-toto attestation",
+}
+
+type layer struct {
+	Title     string `json:"title,omitempty"`
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type summary struct {
+	Reference    string               `json:"reference"`
+	Digest       string               `json:"digest"`
+	MediaType    string               `json:"mediaType"`
+	ArtifactType string               `json:"artifactType"`
+	Kind         string               `json:"kind,omitempty"`
+	Config       manifest.Descriptor  `json:"config"`
+	Subject      *manifest.Descriptor `json:"subject,omitempty"`
+	Layers       []layer              `json:"layers"`
+	Annotations  map[string]string    `json:"annotations,omitempty"`
+	Size         int64                `json:"size"`
+}
+
+func summarize(reference string, m *manifest.Resolved) summary {
+	s := summary{
+		Reference:    reference,
+		Digest:       m.Digest,
+		MediaType:    m.MediaType,
+		ArtifactType: m.Manifest.Type(),
+		Kind:         knownArtifactTypes[m.Manifest.Type()],
+		Config:       m.Manifest.Config,
+		Subject:      m.Manifest.Subject,
+		Layers:       make([]layer, 0, len(m.Manifest.Layers)),
+		Annotations:  m.Manifest.Annotations,
+		Size:         m.Manifest.Config.Size,
+	}
+
+	for _, l := range m.Manifest.Layers {
+		s.Layers = append(s.Layers, layer{
+			Title:     l.Annotations[titleAnnotation],
+			MediaType: l.MediaType,
+			Digest:    l.Digest,
+			Size:      l.Size,
+		})
+		s.Size += l.Size
+	}
+
+	return s
+}
+
+func printSummary(w io.Writer, s summary) {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.AppendRow(table.Row{"Reference", s.Reference})
+	t.AppendRow(table.Row{"Digest", s.Digest})
+	t.AppendRow(table.Row{"Media Type", s.MediaType})
+	if s.Kind != "" {
+		t.AppendRow(table.Row{"Artifact Type", fmt.Sprintf("%s (%s)", s.ArtifactType, s.Kind)})
+	} else {
+		t.AppendRow(table.Row{"Artifact Type", s.ArtifactType})
+	}
+	t.AppendRow(table.Row{"Config", fmt.Sprintf("%s (%d bytes)", s.Config.MediaType, s.Config.Size)})
+	if s.Subject != nil {
+		t.AppendRow(table.Row{"Subject", s.Subject.Digest})
+	}
+	t.AppendRow(table.Row{"Total Size", fmt.Sprintf("%d bytes", s.Size)})
+	t.Render()
+
+	if len(s.Layers) > 0 {
+		t := table.NewWriter()
+		t.SetOutputMirror(w)
+		t.AppendHeader(table.Row{"Title", "Media Type", "Digest", "Size"})
+		for _, l := range s.Layers {
+			t.AppendRow(table.Row{l.Title, l.MediaType, l.Digest, l.Size})
+		}
+		t.Render()
+	}
+
+	if len(s.Annotations) > 0 {
+		keys := make([]string, 0, len(s.Annotations))
+		for k := range s.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		t := table.NewWriter()
+		t.SetOutputMirror(w)
+		t.AppendHeader(table.Row{"Annotation", "Value"})
+		for _, k := range keys {
+			t.AppendRow(table.Row{k, text.WrapSoft(s.Annotations[k], 60)})
+		}
+		t.Render()
+	}
+}
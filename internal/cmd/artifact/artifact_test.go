@@ -0,0 +1,37 @@
+package artifact
+
+import (
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	m := &manifest.Resolved{
+		Digest:    "sha256:abc123",
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Manifest: &manifest.Manifest{
+			Config: manifest.Descriptor{MediaType: "application/vnd.cncf.helm.config.v1+json", Size: 100},
+			Layers: []manifest.Descriptor{
+				{
+					MediaType:   "application/vnd.cncf.helm.chart.content.v1.tar+gzip",
+					Digest:      "sha256:def456",
+					Size:        1000,
+					Annotations: map[string]string{titleAnnotation: "app-1.0.0.tgz"},
+				},
+			},
+		},
+	}
+
+	s := summarize("ghcr.io/org/charts/app:1.0.0", m)
+	require.Equal(t, "application/vnd.cncf.helm.config.v1+json", s.ArtifactType)
+	require.Equal(t, "Helm chart", s.Kind)
+	require.Equal(t, int64(1100), s.Size)
+	require.Equal(t, []layer{{
+		Title:     "app-1.0.0.tgz",
+		MediaType: "application/vnd.cncf.helm.chart.content.v1.tar+gzip",
+		Digest:    "sha256:def456",
+		Size:      1000,
+	}}, s.Layers)
+}
@@ -0,0 +1,155 @@
+package attestations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jcchavezs/nuro/internal/api/attestation"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+)
+
+var Formats = map[OutputFormat][]string{
+	Table: {"table"},
+	JSON:  {"json"},
+}
+
+type OutputFormat int
+
+const (
+	Table OutputFormat = iota
+	JSON
+)
+
+var outputFormat OutputFormat = Table
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&outputFormat, "string", Formats, enumflag.EnumCaseInsensitive),
+		"output",
+		"Sets the output format",
+	)
+	RootCmd.Flags().String("platform", "", "Only lists the attestations for the given platform (e.g. linux/amd64)")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "attestations <image>",
+	Short:   "Lists the BuildKit attestations for a given image and the platform they belong to",
+	Example: "$ nuro attestations docker/dockerfile:1",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		res, err := manifest.Get(ctx, registry, insecure, name, reference)
+		if err != nil {
+			return fmt.Errorf("getting manifest: %w", err)
+		}
+
+		if !res.IsIndex() {
+			return errors.New("no attestations found, image is not multi-platform")
+		}
+
+		idx, err := res.Index()
+		if err != nil {
+			return fmt.Errorf("getting index: %w", err)
+		}
+
+		var attestations []entry
+		for _, a := range idx.Attestations() {
+			if platform != nil && (a.Subject == nil || a.Subject.Platform == nil || !a.Subject.Platform.Matches(*platform)) {
+				continue
+			}
+
+			res, err := manifest.GetByDescriptor(ctx, registry, insecure, name, a.Descriptor)
+			if err != nil {
+				return fmt.Errorf("getting attestation manifest: %w", err)
+			}
+
+			m, err := res.Manifest()
+			if err != nil {
+				return fmt.Errorf("getting attestation manifest: %w", err)
+			}
+
+			attestations = append(attestations, newEntry(a, m))
+		}
+
+		if len(attestations) == 0 {
+			return errors.New("no attestations found")
+		}
+
+		switch outputFormat {
+		case JSON:
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(attestations); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		default:
+			t := table.NewWriter()
+			t.SetOutputMirror(cmd.OutOrStdout())
+			t.AppendHeader(table.Row{"Platform", "Image Digest", "Attestation Digest", "Predicate Types"})
+			for _, a := range attestations {
+				t.AppendRow(table.Row{a.Platform, a.ImageDigest, a.Digest, strings.Join(a.PredicateTypes, "\n")})
+			}
+			t.Render()
+		}
+
+		return nil
+	},
+}
+
+type entry struct {
+	Platform       string   `json:"platform,omitempty"`
+	ImageDigest    string   `json:"imageDigest"`
+	Digest         string   `json:"digest"`
+	Size           int64    `json:"size"`
+	PredicateTypes []string `json:"predicateTypes"`
+}
+
+func newEntry(a manifest.Attestation, m *manifest.Manifest) entry {
+	at := entry{
+		ImageDigest:    a.ImageDigest,
+		Digest:         a.Digest,
+		Size:           a.Size,
+		PredicateTypes: make([]string, 0, len(m.Layers)),
+	}
+
+	if a.Subject != nil && a.Subject.Platform != nil {
+		at.Platform = a.Subject.Platform.String()
+	}
+
+	for _, l := range m.Layers {
+		if pt := l.Annotations[attestation.PredicateTypeAnnotation]; pt != "" {
+			at.PredicateTypes = append(at.PredicateTypes, pt)
+		}
+	}
+
+	return at
+}
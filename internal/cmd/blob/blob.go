@@ -0,0 +1,214 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jcchavezs/nuro/internal/api"
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/jcchavezs/nuro/internal/http"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/spf13/cobra"
+)
+
+// partialExt is the extension of the file the blob is downloaded to before verification
+const partialExt = ".partial"
+
+func init() {
+	getCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	getCmd.Flags().String("output", "", "Writes the blob to the file instead of stdout, resuming a previous interrupted download")
+	getCmd.Flags().Bool("decompress", false, "Decompresses gzip or zstd layers")
+	getCmd.Flags().String("media-type", "", "Sets the media type of the blob used to decompress it, detected from the content otherwise")
+
+	RootCmd.AddCommand(getCmd)
+}
+
+var RootCmd = &cobra.Command{
+	Use:   "blob",
+	Short: "Downloads blobs, e.g. layers or configs",
+	Args:  cobra.NoArgs,
+}
+
+var getCmd = &cobra.Command{
+	Use:     "get <repository>@<digest>",
+	Short:   "Downloads a blob verifying its digest",
+	Example: "$ nuro blob get alpine@sha256:4abcf20661432fb2d719aaf90656f55c287f8ca915dc1c92ec14ff61e67fbaf8 --decompress --output layer.tar",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, _, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		if digest == "" {
+			return errors.New("blob reference must include a digest, e.g. alpine@sha256:...")
+		}
+
+		if _, _, err := content.ParseDigest(digest); err != nil {
+			return err
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		decompress, _ := cmd.Flags().GetBool("decompress")
+		mediaType, _ := cmd.Flags().GetString("media-type")
+
+		if output == "" {
+			r, err := blob.Get(ctx, registry, insecure, name, digest, -1)
+			if err != nil {
+				return fmt.Errorf("getting blob: %w", err)
+			}
+			defer r.Close() //nolint
+
+			// content is written as it is received so a verification failure is only
+			// reported once everything was written
+			return write(cmd.OutOrStdout(), r, decompress, mediaType)
+		}
+
+		partial := output + partialExt
+		if err := download(ctx, cmd.ErrOrStderr(), registry, insecure, name, digest, partial); err != nil {
+			return err
+		}
+
+		if !decompress {
+			return os.Rename(partial, output)
+		}
+
+		f, err := os.Open(partial)
+		if err != nil {
+			return fmt.Errorf("opening downloaded blob: %w", err)
+		}
+		defer f.Close() //nolint
+
+		out, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+
+		if err := write(out, f, true, mediaType); err != nil {
+			_ = out.Close()
+			return err
+		}
+
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("writing output file: %w", err)
+		}
+
+		return os.Remove(partial)
+	},
+}
+
+// write copies the blob into the writer decompressing it if requested. The blob is
+// read until EOF so it gets verified.
+func write(w io.Writer, r io.Reader, decompress bool, mediaType string) error {
+	if decompress {
+		dr, err := blob.Decompress(r, mediaType)
+		if err != nil {
+			return err
+		}
+		defer dr.Close() //nolint
+
+		if _, err := io.Copy(w, dr); err != nil {
+			return fmt.Errorf("writing blob: %w", err)
+		}
+
+		// compressed streams may be followed by padding that is not decompressed
+		w = io.Discard
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("writing blob: %w", err)
+	}
+
+	return nil
+}
+
+// download downloads the blob into the file, resuming from its current size when it
+// already exists. The whole file is verified against the digest once downloaded and
+// removed if it does not match. Progress is rendered on stderr when it is a terminal.
+func download(ctx context.Context, stderr io.Writer, registry string, insecure bool, name, digest, path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening output file: %w", err)
+	}
+	defer f.Close() //nolint
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("opening output file: %w", err)
+	}
+
+	body, offset, size, err := blob.GetFrom(ctx, registry, insecure, name, digest, info.Size())
+	var sErr *api.StatusError
+	if errors.As(err, &sErr) && sErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// the partial file may already hold the whole blob, otherwise it is not a prefix
+		// of the blob and it is downloaded again
+		if verify(f, -1, digest) == nil {
+			return nil
+		}
+
+		body, offset, size, err = blob.GetFrom(ctx, registry, insecure, name, digest, 0)
+	}
+	if err != nil {
+		return fmt.Errorf("getting blob: %w", err)
+	}
+	defer body.Close() //nolint
+
+	if err := f.Truncate(offset); err != nil {
+		return fmt.Errorf("truncating output file: %w", err)
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking output file: %w", err)
+	}
+
+	var w io.Writer = f
+	p := newProgress(stderr, offset, size)
+	if p != nil {
+		w = io.MultiWriter(f, p)
+	}
+
+	if _, err := io.Copy(w, body); err != nil {
+		return fmt.Errorf("downloading blob: %w", err)
+	}
+
+	if p != nil {
+		p.Finish()
+	}
+
+	if err := verify(f, size, digest); err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+// verify reads the file from the start verifying it against the size and digest
+func verify(f *os.File, size int64, digest string) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking output file: %w", err)
+	}
+
+	vr, err := content.NewVerifyingReader(f, size, digest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(io.Discard, vr); err != nil {
+		return fmt.Errorf("verifying blob: %w", err)
+	}
+
+	return nil
+}
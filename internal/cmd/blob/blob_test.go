@@ -0,0 +1,132 @@
+package blob
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGet(t *testing.T) {
+	reg := registrytest.New(t)
+	reg.Token = "s3cr3t"
+
+	require.NoError(t, auth.LoadNetRC(context.Background(), "machine "+reg.Host()+" login user password s3cr3t"))
+	t.Cleanup(func() { _ = auth.LoadNetRC(context.Background(), "") })
+
+	data := bytes.Repeat([]byte("layer content "), 1000)
+	d := reg.PutBlob(data, "application/octet-stream")
+	ref := reg.Host() + "/org/app@" + d.Digest
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	compressed := reg.PutBlob(gz.Bytes(), "application/vnd.oci.image.layer.v1.tar+gzip")
+
+	blobPath := "/v2/org/app/blobs/" + d.Digest
+
+	t.Run("stdout", func(t *testing.T) {
+		out, err := registrytest.Execute(t, RootCmd, "get", ref, "--insecure")
+		require.NoError(t, err)
+		require.Equal(t, string(data), out)
+	})
+
+	t.Run("decompress", func(t *testing.T) {
+		out, err := registrytest.Execute(t, RootCmd, "get", reg.Host()+"/org/app@"+compressed.Digest, "--insecure", "--decompress")
+		require.NoError(t, err)
+		require.Equal(t, string(data), out)
+	})
+
+	t.Run("output", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "layer")
+
+		_, err := registrytest.Execute(t, RootCmd, "get", ref, "--insecure", "--output", output)
+		require.NoError(t, err)
+
+		b, err := os.ReadFile(output)
+		require.NoError(t, err)
+		require.Equal(t, data, b)
+		require.NoFileExists(t, output+partialExt)
+	})
+
+	t.Run("output decompressed", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "layer.tar")
+
+		_, err := registrytest.Execute(t, RootCmd, "get", reg.Host()+"/org/app@"+compressed.Digest, "--insecure", "--output", output, "--decompress")
+		require.NoError(t, err)
+
+		b, err := os.ReadFile(output)
+		require.NoError(t, err)
+		require.Equal(t, data, b)
+		require.NoFileExists(t, output+partialExt)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "layer")
+		require.NoError(t, os.WriteFile(output+partialExt, data[:len(data)/2], 0o600))
+
+		_, err := registrytest.Execute(t, RootCmd, "get", ref, "--insecure", "--output", output)
+		require.NoError(t, err)
+
+		b, err := os.ReadFile(output)
+		require.NoError(t, err)
+		require.Equal(t, data, b)
+	})
+
+	t.Run("resume from corrupted partial", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "layer")
+		require.NoError(t, os.WriteFile(output+partialExt, bytes.Repeat([]byte("x"), len(data)/2), 0o600))
+
+		// only the missing bytes are downloaded so the verification fails
+		_, err := registrytest.Execute(t, RootCmd, "get", ref, "--insecure", "--output", output)
+		require.ErrorContains(t, err, "verifying blob")
+		require.NoFileExists(t, output)
+		require.NoFileExists(t, output+partialExt)
+	})
+
+	t.Run("already downloaded", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "layer")
+		require.NoError(t, os.WriteFile(output+partialExt, data, 0o600))
+
+		requests := reg.Requests(http.MethodGet, blobPath)
+		_, err := registrytest.Execute(t, RootCmd, "get", ref, "--insecure", "--output", output)
+		require.NoError(t, err)
+		// the range request is not satisfiable and nothing else is requested
+		require.Equal(t, requests+1, reg.Requests(http.MethodGet, blobPath))
+
+		b, err := os.ReadFile(output)
+		require.NoError(t, err)
+		require.Equal(t, data, b)
+	})
+
+	t.Run("longer partial", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "layer")
+		require.NoError(t, os.WriteFile(output+partialExt, append(bytes.Clone(data), "garbage"...), 0o600))
+
+		_, err := registrytest.Execute(t, RootCmd, "get", ref, "--insecure", "--output", output)
+		require.NoError(t, err)
+
+		b, err := os.ReadFile(output)
+		require.NoError(t, err)
+		require.Equal(t, data, b)
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		require.NoError(t, auth.LoadNetRC(context.Background(), ""))
+		t.Cleanup(func() {
+			require.NoError(t, auth.LoadNetRC(context.Background(), "machine "+reg.Host()+" login user password s3cr3t"))
+		})
+
+		_, err := registrytest.Execute(t, RootCmd, "get", ref, "--insecure", "--output", filepath.Join(t.TempDir(), "layer"))
+		require.ErrorContains(t, err, "401")
+	})
+}
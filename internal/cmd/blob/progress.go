@@ -0,0 +1,71 @@
+package blob
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/bytesize"
+)
+
+const (
+	progressWidth    = 30
+	progressInterval = 100 * time.Millisecond
+)
+
+// progress renders a progress bar for the bytes written through it
+type progress struct {
+	out     io.Writer
+	current int64
+	total   int64
+	last    time.Time
+}
+
+// newProgress returns a progress bar on the writer or nil when it is not a terminal
+func newProgress(out io.Writer, offset, total int64) *progress {
+	f, ok := out.(*os.File)
+	if !ok {
+		return nil
+	}
+
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+
+	return &progress{out: f, current: offset, total: total}
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.current += int64(len(b))
+	if time.Since(p.last) >= progressInterval {
+		p.render()
+	}
+
+	return len(b), nil
+}
+
+func (p *progress) render() {
+	p.last = time.Now()
+
+	if p.total <= 0 {
+		_, _ = fmt.Fprintf(p.out, "\r%s", bytesize.Format(p.current))
+		return
+	}
+
+	done := int(float64(progressWidth) * float64(p.current) / float64(p.total))
+	done = min(done, progressWidth)
+
+	_, _ = fmt.Fprintf(p.out, "\r[%s%s] %3d%% %s/%s",
+		strings.Repeat("=", done), strings.Repeat(" ", progressWidth-done),
+		p.current*100/p.total, bytesize.Format(p.current), bytesize.Format(p.total),
+	)
+}
+
+// Finish renders the final state and ends the line
+func (p *progress) Finish() {
+	p.render()
+	_, _ = fmt.Fprintln(p.out)
+}
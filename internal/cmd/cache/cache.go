@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/bytesize"
+	"github.com/jcchavezs/nuro/internal/cache"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+)
+
+var Formats = map[OutputFormat][]string{
+	Table: {"table"},
+	JSON:  {"json"},
+}
+
+type OutputFormat int
+
+const (
+	Table OutputFormat = iota
+	JSON
+)
+
+var outputFormat OutputFormat = Table
+
+func init() {
+	lsCmd.Flags().Var(
+		enumflag.New(&outputFormat, "string", Formats, enumflag.EnumCaseInsensitive),
+		"output",
+		"Sets the output format",
+	)
+	pruneCmd.Flags().String("max-size", "", "Sets the maximum size of the cache, least recently used content is removed first (e.g. 500MB, 2GiB), defaults to --cache-max-size")
+
+	RootCmd.AddCommand(lsCmd)
+	RootCmd.AddCommand(pruneCmd)
+	RootCmd.AddCommand(clearCmd)
+}
+
+var RootCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manages the on-disk cache of manifests, blobs and tag resolutions",
+	Long: "Manages the on-disk cache of manifests, blobs and tag resolutions stored under $XDG_CACHE_HOME/nuro. " +
+		"Manifests and blobs are stored by digest, tag resolutions expire after --cache-ttl.",
+	Args: cobra.NoArgs,
+}
+
+var lsCmd = &cobra.Command{
+	Use:     "ls",
+	Short:   "Lists the cached content",
+	Example: "$ nuro cache ls",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := cache.DefaultDir()
+		if err != nil {
+			return err
+		}
+
+		entries, err := cache.List(dir)
+		if err != nil {
+			return fmt.Errorf("listing cache: %w", err)
+		}
+
+		switch outputFormat {
+		case JSON:
+			if entries == nil {
+				entries = []cache.Entry{}
+			}
+
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(entries); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		default:
+			var total int64
+
+			t := table.NewWriter()
+			t.SetOutputMirror(cmd.OutOrStdout())
+			t.AppendHeader(table.Row{"Kind", "Reference", "Size", "Last Used"})
+			for _, e := range entries {
+				ref := e.Reference
+				if e.Digest != "" {
+					ref += "@" + e.Digest
+				}
+
+				t.AppendRow(table.Row{e.Kind, ref, bytesize.Format(e.Size), e.LastUsed.Format(time.RFC3339)})
+				total += e.Size
+			}
+			t.AppendFooter(table.Row{"", fmt.Sprintf("%d entries", len(entries)), bytesize.Format(total), ""})
+			t.Render()
+		}
+
+		return nil
+	},
+}
+
+var pruneCmd = &cobra.Command{
+	Use:     "prune",
+	Short:   "Removes expired tag resolutions and the least recently used content over the size limit",
+	Example: "$ nuro cache prune --max-size 500MB",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := cache.DefaultDir()
+		if err != nil {
+			return err
+		}
+
+		s, _ := cmd.Flags().GetString("max-size")
+		if s == "" {
+			s, _ = cmd.Flags().GetString("cache-max-size")
+		}
+
+		maxSize, err := bytesize.Parse(s)
+		if err != nil {
+			return fmt.Errorf("parsing max size: %w", err)
+		}
+
+		ttl, err := cmd.Flags().GetDuration("cache-ttl")
+		if err != nil {
+			return fmt.Errorf("getting cache-ttl flag: %w", err)
+		}
+
+		res, err := cache.Prune(dir, maxSize, ttl)
+		if err != nil {
+			return fmt.Errorf("pruning cache: %w", err)
+		}
+
+		if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Removed %d entries, freed %s\n", res.Removed, bytesize.Format(res.Freed)); err != nil {
+			return fmt.Errorf("writing to stdout: %w", err)
+		}
+
+		return nil
+	},
+}
+
+var clearCmd = &cobra.Command{
+	Use:     "clear",
+	Short:   "Removes all the cached content",
+	Example: "$ nuro cache clear",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := cache.DefaultDir()
+		if err != nil {
+			return err
+		}
+
+		if err := cache.Clear(dir); err != nil {
+			return fmt.Errorf("clearing cache: %w", err)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,95 @@
+package cat
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jcchavezs/nuro/internal/layer"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().String("platform", "", "Reads the file from the image for the given platform (e.g. linux/amd64)")
+	RootCmd.Flags().String("output", "", "Writes the file to the given path instead of stdout, keeping its permissions")
+}
+
+var RootCmd = &cobra.Command{
+	Use:   "cat <image> <path>",
+	Short: "Shows the content of a file in a given image without pulling it",
+	Long: "Shows the content of a file in a given image. Layers are read from the top down and only until " +
+		"the one containing the file, symlinks are followed within the image.",
+	Example: "$ nuro cat alpine:3.18 /etc/os-release",
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		m, err := manifest.Resolve(ctx, registry, insecure, name, reference, platform)
+		if err != nil {
+			return fmt.Errorf("resolving manifest: %w", err)
+		}
+
+		manifest.WarnSchema1(cmd, m)
+
+		output, _ := cmd.Flags().GetString("output")
+		if output == "" {
+			if _, err := layer.Extract(ctx, registry, insecure, name, m.Manifest.Layers, args[1], cmd.OutOrStdout()); err != nil {
+				return fmt.Errorf("reading file: %w", err)
+			}
+
+			return nil
+		}
+
+		// the file is written next to the output and only moved into place once the
+		// layer it comes from is verified
+		f, err := os.CreateTemp(filepath.Dir(output), ".nuro-*")
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer os.Remove(f.Name()) //nolint
+
+		e, err := layer.Extract(ctx, registry, insecure, name, m.Manifest.Layers, args[1], f)
+		if err != nil {
+			_ = f.Close()
+			return fmt.Errorf("reading file: %w", err)
+		}
+
+		if err := f.Chmod(fs.FileMode(e.Mode) & fs.ModePerm); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("writing output file: %w", err)
+		}
+
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("writing output file: %w", err)
+		}
+
+		return os.Rename(f.Name(), output)
+	},
+}
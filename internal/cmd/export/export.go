@@ -0,0 +1,140 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jcchavezs/nuro/internal/layout"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().String("platform", "", "Exports only the image for the given platform (e.g. linux/amd64) instead of the whole index")
+	RootCmd.Flags().Bool("docker", false, "Also writes manifest.json and repositories so the export can be loaded with docker load, requires a single platform")
+}
+
+var RootCmd = &cobra.Command{
+	Use:   "export <image> <dest>",
+	Short: "Exports a given image into an OCI image layout without a docker daemon",
+	Long: "Exports a given image with all its content into an OCI image layout, a tarball when dest ends with .tar " +
+		"and a directory otherwise. Exporting into an existing directory reuses the blobs already present and adds " +
+		"the image to its index.",
+	Example: "$ nuro export alpine:3.18 alpine.tar --platform linux/amd64 --docker",
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		dest := args[1]
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		docker, _ := cmd.Flags().GetBool("docker")
+
+		res, err := manifest.Get(ctx, registry, insecure, name, reference)
+		if err != nil {
+			return fmt.Errorf("getting manifest: %w", err)
+		}
+
+		root := manifest.Descriptor{MediaType: res.MediaType, Digest: res.Digest, Size: int64(len(res.Body))}
+		if res.IsIndex() {
+			switch {
+			case platform != nil:
+				idx, err := res.Index()
+				if err != nil {
+					return fmt.Errorf("parsing index: %w", err)
+				}
+
+				if root, err = manifest.SelectManifest(idx, platform); err != nil {
+					return fmt.Errorf("selecting manifest: %w", err)
+				}
+
+				// the exporter fetches the manifest of the selected platform
+				res = nil
+			case docker:
+				return layout.ErrNotSingleImage
+			}
+		}
+
+		if !strings.HasSuffix(dest, ".tar") {
+			w, err := layout.NewDirWriter(dest)
+			if err != nil {
+				return err
+			}
+
+			if err := export(ctx, registry, insecure, name, tag, root, res, w, docker); err != nil {
+				return err
+			}
+		} else {
+			// the tarball is moved into place only once complete
+			f, err := os.CreateTemp(filepath.Dir(dest), ".nuro-*")
+			if err != nil {
+				return fmt.Errorf("creating tarball: %w", err)
+			}
+			defer os.Remove(f.Name()) //nolint
+
+			if err := export(ctx, registry, insecure, name, tag, root, res, layout.NewTarWriter(f), docker); err != nil {
+				_ = f.Close()
+				return err
+			}
+
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("writing tarball: %w", err)
+			}
+
+			if err := os.Chmod(f.Name(), 0o644); err != nil {
+				return fmt.Errorf("writing tarball: %w", err)
+			}
+
+			if err := os.Rename(f.Name(), dest); err != nil {
+				return fmt.Errorf("writing tarball: %w", err)
+			}
+		}
+
+		if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Exported %s to %s\n", image.FormatReference(registry, name, tag, root.Digest), dest); err != nil {
+			return fmt.Errorf("writing to stdout: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func export(ctx context.Context, registry string, insecure bool, name, tag string, root manifest.Descriptor, res *manifest.Response, w layout.Writer, docker bool) error {
+	e := layout.NewExporter(registry, insecure, name, w, docker)
+	if err := e.Add(ctx, root, res, tag); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("exporting image: %w", err)
+	}
+
+	if err := e.Close(); err != nil {
+		return fmt.Errorf("exporting image: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package export
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/jcchavezs/nuro/internal/layout"
+	"github.com/jcchavezs/nuro/internal/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+// readTar returns the content of the files in the tarball by name
+func readTar(t *testing.T, path string) map[string][]byte {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close() //nolint
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(f)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[h.Name] = b
+	}
+}
+
+func blobPath(digest string) string {
+	return "blobs/" + strings.Replace(digest, ":", "/", 1)
+}
+
+func TestExport(t *testing.T) {
+	reg := registrytest.New(t)
+
+	blobs := map[string][]byte{}
+	var images []manifest.Descriptor
+	for _, arch := range []string{"amd64", "arm64"} {
+		config := []byte(`{"architecture":"` + arch + `","os":"linux"}`)
+		layers := [][]byte{[]byte("base layer"), []byte(arch + " layer")}
+
+		d := reg.PutImage(t, config, layers)
+		d.Platform = &manifest.Platform{OS: "linux", Architecture: arch}
+		images = append(images, d)
+
+		for _, b := range append([][]byte{config}, layers...) {
+			blobs[content.FromBytes(b)] = b
+		}
+	}
+
+	idx := reg.PutManifest(t, manifest.Index{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIIndexV1ContentType,
+		Manifests:     images,
+	}, "latest")
+
+	ref := reg.Host() + "/org/app:latest"
+	manifestRequests := func(reference string) int {
+		return reg.Requests(http.MethodGet, "/v2/org/app/manifests/"+reference)
+	}
+
+	t.Run("directory", func(t *testing.T) {
+		dest := t.TempDir()
+		latest, byDigest := manifestRequests("latest"), manifestRequests(idx.Digest)
+
+		out, err := registrytest.Execute(t, RootCmd, ref, dest, "--insecure")
+		require.NoError(t, err)
+		require.Equal(t, "Exported "+ref+"@"+idx.Digest+" to "+dest+"\n", out)
+
+		// the index is only fetched once
+		require.Equal(t, latest+1, manifestRequests("latest"))
+		require.Equal(t, byDigest, manifestRequests(idx.Digest))
+
+		for digest, expected := range blobs {
+			b, err := os.ReadFile(filepath.Join(dest, blobPath(digest)))
+			require.NoError(t, err)
+			require.Equal(t, expected, b)
+		}
+
+		for _, d := range append(images, idx) {
+			b, err := os.ReadFile(filepath.Join(dest, blobPath(d.Digest)))
+			require.NoError(t, err)
+
+			expected, ok := reg.Manifest(d.Digest)
+			require.True(t, ok)
+			require.Equal(t, expected, b)
+		}
+
+		b, err := os.ReadFile(filepath.Join(dest, "index.json"))
+		require.NoError(t, err)
+
+		var index manifest.Index
+		require.NoError(t, json.Unmarshal(b, &index))
+		require.Len(t, index.Manifests, 1)
+		require.Equal(t, idx.Digest, index.Manifests[0].Digest)
+		require.Equal(t, "latest", index.Manifests[0].Annotations["org.opencontainers.image.ref.name"])
+	})
+
+	t.Run("docker tarball", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "app.tar")
+
+		out, err := registrytest.Execute(t, RootCmd, ref, dest, "--insecure", "--platform", "linux/arm64", "--docker")
+		require.NoError(t, err)
+		require.Equal(t, "Exported "+ref+"@"+images[1].Digest+" to "+dest+"\n", out)
+
+		files := readTar(t, dest)
+		require.JSONEq(t, `{"imageLayoutVersion":"1.0.0"}`, string(files["oci-layout"]))
+
+		b, ok := reg.Manifest(images[1].Digest)
+		require.True(t, ok)
+		require.Equal(t, b, files[blobPath(images[1].Digest)])
+
+		var m manifest.Manifest
+		require.NoError(t, json.Unmarshal(b, &m))
+
+		var saved []struct {
+			Config   string
+			RepoTags []string
+			Layers   []string
+		}
+		require.NoError(t, json.Unmarshal(files["manifest.json"], &saved))
+		require.Len(t, saved, 1)
+		require.Equal(t, []string{ref}, saved[0].RepoTags)
+		require.Equal(t, blobPath(m.Config.Digest), saved[0].Config)
+		require.Equal(t, `{"architecture":"arm64","os":"linux"}`, string(files[saved[0].Config]))
+
+		require.Len(t, saved[0].Layers, 2)
+		require.Equal(t, "base layer", string(files[saved[0].Layers[0]]))
+		require.Equal(t, "arm64 layer", string(files[saved[0].Layers[1]]))
+
+		var repositories map[string]map[string]string
+		require.NoError(t, json.Unmarshal(files["repositories"], &repositories))
+		_, top, _ := strings.Cut(m.Layers[1].Digest, ":")
+		require.Equal(t, map[string]map[string]string{reg.Host() + "/org/app": {"latest": top}}, repositories)
+
+		var index manifest.Index
+		require.NoError(t, json.Unmarshal(files["index.json"], &index))
+		require.Len(t, index.Manifests, 1)
+		require.Equal(t, images[1].Digest, index.Manifests[0].Digest)
+	})
+
+	t.Run("docker requires a platform", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "app.tar")
+
+		_, err := registrytest.Execute(t, RootCmd, ref, dest, "--insecure", "--docker")
+		require.ErrorIs(t, err, layout.ErrNotSingleImage)
+		require.NoFileExists(t, dest)
+	})
+}
@@ -0,0 +1,174 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+)
+
+var Formats = map[OutputFormat][]string{
+	Table: {"table"},
+	JSON:  {"json"},
+}
+
+type OutputFormat int
+
+const (
+	Table OutputFormat = iota
+	JSON
+)
+
+var outputFormat OutputFormat = Table
+
+const truncateLength = 60
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&outputFormat, "string", Formats, enumflag.EnumCaseInsensitive),
+		"output",
+		"Sets the output format",
+	)
+	RootCmd.Flags().String("platform", "", "Shows the history of the image for the given platform (e.g. linux/amd64)")
+	RootCmd.Flags().Bool("no-trunc", false, "Does not truncate the commands in table output")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "history <image>",
+	Short:   "Shows the build history of a given image",
+	Long:    "Shows the build history of a given image in build order, pairing every step that created a layer with the layer in the manifest",
+	Example: "$ nuro history alpine:3.18 --no-trunc",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		m, err := manifest.Resolve(ctx, registry, insecure, name, reference, platform)
+		if err != nil {
+			return fmt.Errorf("resolving manifest: %w", err)
+		}
+
+		manifest.WarnSchema1(cmd, m)
+
+		cfg, err := blob.GetConfig(ctx, registry, insecure, name, m)
+		if err != nil {
+			return fmt.Errorf("getting config blob: %w", err)
+		}
+
+		steps, ok := pairHistory(cfg.History, m.Manifest.Layers)
+		if !ok {
+			cmd.PrintErrln("Warning: the history does not match the layers of the image, layer information might be missing")
+		}
+
+		switch outputFormat {
+		case JSON:
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(steps); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		default:
+			noTrunc, _ := cmd.Flags().GetBool("no-trunc")
+
+			t := table.NewWriter()
+			t.SetOutputMirror(cmd.OutOrStdout())
+			t.AppendHeader(table.Row{"Created", "Created By", "Size", "Comment"})
+			for _, s := range steps {
+				createdBy := s.CreatedBy
+				if !noTrunc {
+					createdBy = truncate(createdBy, truncateLength)
+				}
+
+				created := ""
+				if !s.Created.IsZero() {
+					created = s.Created.Format(time.RFC3339)
+				}
+
+				size := "-"
+				if s.Layer != nil {
+					size = fmt.Sprint(s.Layer.Size)
+				}
+
+				t.AppendRow(table.Row{created, createdBy, size, s.Comment})
+			}
+			t.Render()
+		}
+
+		return nil
+	},
+}
+
+type step struct {
+	Created    time.Time `json:"created,omitempty"`
+	CreatedBy  string    `json:"createdBy,omitempty"`
+	Author     string    `json:"author,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	EmptyLayer bool      `json:"emptyLayer,omitempty"`
+	// Layer is the layer created by the step, nil for empty layers
+	Layer *manifest.Descriptor `json:"layer,omitempty"`
+}
+
+// pairHistory pairs the history entries which created a layer with the layers of the
+// manifest in order. It returns false when the number of non empty entries and layers
+// differ, in which case the unmatched entries are left without layer.
+func pairHistory(history []blob.History, layers []manifest.Descriptor) ([]step, bool) {
+	steps := make([]step, 0, len(history))
+	next := 0
+	for _, h := range history {
+		s := step{
+			Created:    h.Created,
+			CreatedBy:  h.CreatedBy,
+			Author:     h.Author,
+			Comment:    h.Comment,
+			EmptyLayer: h.EmptyLayer,
+		}
+
+		if !h.EmptyLayer {
+			if next < len(layers) {
+				l := layers[next]
+				s.Layer = &l
+			}
+			next++
+		}
+
+		steps = append(steps, s)
+	}
+
+	return steps, next == len(layers)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+
+	return string(r[:n-3]) + "..."
+}
@@ -0,0 +1,72 @@
+package history
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPairHistory(t *testing.T) {
+	history := []blob.History{
+		{CreatedBy: "ADD rootfs.tar /"},
+		{CreatedBy: "ENV A=1", EmptyLayer: true},
+		{CreatedBy: "COPY app /app"},
+	}
+
+	layers := []manifest.Descriptor{{Digest: "sha256:base", Size: 100}, {Digest: "sha256:app", Size: 10}}
+
+	steps, ok := pairHistory(history, layers)
+	require.True(t, ok)
+	require.Len(t, steps, 3)
+	require.Equal(t, "sha256:base", steps[0].Layer.Digest)
+	require.Nil(t, steps[1].Layer)
+	require.Equal(t, "sha256:app", steps[2].Layer.Digest)
+
+	steps, ok = pairHistory(history, layers[:1])
+	require.False(t, ok)
+	require.Equal(t, "sha256:base", steps[0].Layer.Digest)
+	require.Nil(t, steps[2].Layer)
+
+	_, ok = pairHistory(history[:1], layers)
+	require.False(t, ok)
+}
+
+func TestTruncate(t *testing.T) {
+	require.Equal(t, "short", truncate("short", 10))
+	require.Equal(t, "/bin/sh...", truncate("/bin/sh -c apk add curl", 10))
+}
+
+func TestHistorySchema1(t *testing.T) {
+	reg := registrytest.New(t)
+	reg.PutRawManifest([]byte(`{
+		"schemaVersion": 1,
+		"name": "org/app",
+		"tag": "latest",
+		"architecture": "amd64",
+		"fsLayers": [{"blobSum": "sha256:top"}, {"blobSum": "sha256:empty"}, {"blobSum": "sha256:bottom"}],
+		"history": [
+			{"v1Compatibility": "{\"architecture\":\"amd64\",\"os\":\"linux\",\"container_config\":{\"Cmd\":[\"COPY app /\"]}}"},
+			{"v1Compatibility": "{\"throwaway\":true,\"container_config\":{\"Cmd\":[\"ENV A=b\"]}}"},
+			{"v1Compatibility": "{\"container_config\":{\"Cmd\":[\"ADD rootfs /\"]}}"}
+		]
+	}`), "application/vnd.docker.distribution.manifest.v1+json", "latest")
+
+	out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:latest", "--insecure", "--output", "json")
+	require.NoError(t, err)
+
+	var steps []step
+	require.NoError(t, json.Unmarshal([]byte(out), &steps))
+	require.Len(t, steps, 3)
+
+	require.Equal(t, "ADD rootfs /", steps[0].CreatedBy)
+	require.Equal(t, "sha256:bottom", steps[0].Layer.Digest)
+	require.Equal(t, "ENV A=b", steps[1].CreatedBy)
+	require.True(t, steps[1].EmptyLayer)
+	require.Nil(t, steps[1].Layer)
+	require.Equal(t, "COPY app /", steps[2].CreatedBy)
+	require.Equal(t, "sha256:top", steps[2].Layer.Digest)
+}
@@ -0,0 +1,222 @@
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+	"gopkg.in/yaml.v3"
+)
+
+var Formats = map[OutputFormat][]string{
+	Table: {"table"},
+	JSON:  {"json"},
+	YAML:  {"yaml"},
+}
+
+type OutputFormat int
+
+const (
+	Table OutputFormat = iota
+	JSON
+	YAML
+)
+
+var outputFormat OutputFormat = Table
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&outputFormat, "string", Formats, enumflag.EnumCaseInsensitive),
+		"output",
+		"Sets the output format",
+	)
+	RootCmd.Flags().String("platform", "", "Inspects the image for the given platform (e.g. linux/amd64)")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "inspect <image>",
+	Short:   "Shows a summary of the manifest and config of a given image",
+	Example: "$ nuro inspect alpine:3.18 --output yaml",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		m, err := manifest.Resolve(ctx, registry, insecure, name, reference, platform)
+		if err != nil {
+			return fmt.Errorf("resolving manifest: %w", err)
+		}
+
+		manifest.WarnSchema1(cmd, m)
+
+		cfg, err := blob.GetConfig(ctx, registry, insecure, name, m)
+		if err != nil {
+			return fmt.Errorf("getting config blob: %w", err)
+		}
+
+		s := newSummary(registry, name, tag, m, cfg)
+
+		switch outputFormat {
+		case JSON:
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(s); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		case YAML:
+			enc := yaml.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent(2)
+			if err = enc.Encode(s); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		default:
+			printSummary(cmd, s)
+		}
+
+		return nil
+	},
+}
+
+type summary struct {
+	Reference    string            `json:"reference" yaml:"reference"`
+	Digest       string            `json:"digest" yaml:"digest"`
+	MediaType    string            `json:"mediaType" yaml:"mediaType"`
+	IndexDigest  string            `json:"indexDigest,omitempty" yaml:"indexDigest,omitempty"`
+	Platform     string            `json:"platform" yaml:"platform"`
+	Created      *time.Time        `json:"created,omitempty" yaml:"created,omitempty"`
+	Author       string            `json:"author,omitempty" yaml:"author,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Env          []string          `json:"env,omitempty" yaml:"env,omitempty"`
+	Entrypoint   []string          `json:"entrypoint,omitempty" yaml:"entrypoint,omitempty"`
+	Cmd          []string          `json:"cmd,omitempty" yaml:"cmd,omitempty"`
+	User         string            `json:"user,omitempty" yaml:"user,omitempty"`
+	WorkingDir   string            `json:"workingDir,omitempty" yaml:"workingDir,omitempty"`
+	ExposedPorts []string          `json:"exposedPorts,omitempty" yaml:"exposedPorts,omitempty"`
+	Volumes      []string          `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	Layers       int               `json:"layers" yaml:"layers"`
+	// Size is the total compressed size of the layers, unknown for schema1 manifests
+	Size int64 `json:"size" yaml:"size"`
+}
+
+func newSummary(registry, name, tag string, m *manifest.Resolved, cfg *blob.ConfigBlob) summary {
+	s := summary{
+		Reference:    image.FormatReference(registry, name, tag, m.Digest),
+		Digest:       m.Digest,
+		MediaType:    m.MediaType,
+		IndexDigest:  m.IndexDigest,
+		Platform:     cfg.Platform().String(),
+		Author:       cfg.Author,
+		Labels:       cfg.Config.Labels,
+		Env:          cfg.Config.Env,
+		Entrypoint:   cfg.Config.Entrypoint,
+		Cmd:          cfg.Config.Cmd,
+		User:         cfg.Config.User,
+		WorkingDir:   cfg.Config.WorkingDir,
+		ExposedPorts: sortedKeys(cfg.Config.ExposedPorts),
+		Volumes:      sortedKeys(cfg.Config.Volumes),
+		Layers:       len(m.Manifest.Layers),
+	}
+
+	if !cfg.Created.IsZero() {
+		s.Created = &cfg.Created
+	}
+
+	for _, l := range m.Manifest.Layers {
+		s.Size += l.Size
+	}
+
+	return s
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func printSummary(cmd *cobra.Command, s summary) {
+	labels := make([]string, 0, len(s.Labels))
+	for k, v := range s.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+
+	created := ""
+	if s.Created != nil {
+		created = s.Created.Format(time.RFC3339)
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(cmd.OutOrStdout())
+	t.AppendRows([]table.Row{
+		{"Reference", s.Reference},
+		{"Digest", s.Digest},
+		{"Media Type", s.MediaType},
+	})
+	if s.IndexDigest != "" {
+		t.AppendRow(table.Row{"Index Digest", s.IndexDigest})
+	}
+	t.AppendRows([]table.Row{
+		{"Platform", s.Platform},
+		{"Created", created},
+		{"Author", s.Author},
+		{"Labels", strings.Join(labels, "\n")},
+		{"Env", strings.Join(s.Env, "\n")},
+		{"Entrypoint", formatCommand(s.Entrypoint)},
+		{"Cmd", formatCommand(s.Cmd)},
+		{"User", s.User},
+		{"Working Dir", s.WorkingDir},
+		{"Exposed Ports", strings.Join(s.ExposedPorts, ", ")},
+		{"Volumes", strings.Join(s.Volumes, ", ")},
+		{"Layers", s.Layers},
+		{"Size", s.Size},
+	})
+	t.Render()
+}
+
+// formatCommand prints the command in exec form, as it would be written in a Dockerfile
+func formatCommand(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	b, _ := json.Marshal(args)
+	return string(b)
+}
@@ -0,0 +1,51 @@
+package inspect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSummary(t *testing.T) {
+	m := &manifest.Resolved{
+		Digest:      "sha256:aaa",
+		IndexDigest: "sha256:bbb",
+		MediaType:   manifest.OCIManifestV1ContentType,
+		Manifest: &manifest.Manifest{
+			Layers: []manifest.Descriptor{{Size: 100}, {Size: 50}},
+		},
+	}
+
+	cfg := &blob.ConfigBlob{
+		Created:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Architecture: "arm64",
+		OS:           "linux",
+		Variant:      "v8",
+		Config: blob.Config{
+			Env:          []string{"A=1"},
+			ExposedPorts: map[string]struct{}{"8080/tcp": {}, "53/udp": {}},
+			Volumes:      map[string]struct{}{"/data": {}},
+		},
+	}
+
+	s := newSummary("registry.example.com", "app", "v1", m, cfg)
+	require.Equal(t, "registry.example.com/app:v1@sha256:aaa", s.Reference)
+	require.Equal(t, "linux/arm64/v8", s.Platform)
+	require.Equal(t, []string{"53/udp", "8080/tcp"}, s.ExposedPorts)
+	require.Equal(t, []string{"/data"}, s.Volumes)
+	require.Equal(t, 2, s.Layers)
+	require.Equal(t, int64(150), s.Size)
+	require.Equal(t, cfg.Created, *s.Created)
+
+	s = newSummary("registry.example.com", "app", "v1", m, &blob.ConfigBlob{})
+	require.Nil(t, s.Created)
+	require.Nil(t, s.ExposedPorts)
+}
+
+func TestFormatCommand(t *testing.T) {
+	require.Equal(t, "", formatCommand(nil))
+	require.Equal(t, `["/bin/sh","-c","echo \"hi\""]`, formatCommand([]string{"/bin/sh", "-c", `echo "hi"`}))
+}
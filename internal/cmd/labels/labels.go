@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"sort"
 
 	"github.com/jcchavezs/nuro/internal/api/blob"
 	"github.com/jcchavezs/nuro/internal/api/manifest"
@@ -36,11 +38,17 @@ func init() {
 		"output",
 		"Sets the output format",
 	)
+	RootCmd.Flags().StringSlice(
+		"precedence",
+		defaultPrecedence,
+		"Sets which source wins when a label is found in more than one of config, manifest and index",
+	)
 }
 
 var RootCmd = &cobra.Command{
 	Use:     "labels <image>",
 	Short:   "Shows labels for a given image",
+	Long:    "Shows labels for a given image, merged from the config labels, the image manifest annotations and the index annotations",
 	Args:    cobra.ExactArgs(1),
 	Example: "$ nuro labels alpine:3.18.12",
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -61,22 +69,45 @@ var RootCmd = &cobra.Command{
 			return fmt.Errorf("getting insecure flag: %w", err)
 		}
 
-		d, err := manifest.GetConfigDigestFromManifest(ctx, registry, insecure, name, reference)
+		m, err := manifest.Resolve(ctx, registry, insecure, name, reference, nil)
 		if err != nil {
-			return fmt.Errorf("getting config digest from manifest: %w", err)
+			return fmt.Errorf("resolving manifest: %w", err)
 		}
 
-		cfg, err := blob.GetConfigBlob(ctx, registry, insecure, name, d)
+		manifest.WarnSchema1(cmd, m)
+
+		cfg, err := blob.GetConfig(ctx, registry, insecure, name, m)
 		if err != nil {
 			return fmt.Errorf("getting labels from config blob: %w", err)
 		}
 
-		var l map[string]string
-		if len(cfg.Annotations) != 0 {
-			l = cfg.Annotations
-		} else if len(cfg.Config.Labels) != 0 {
-			l = cfg.Config.Labels
-		} else {
+		precedence, err := cmd.Flags().GetStringSlice("precedence")
+		if err != nil {
+			return fmt.Errorf("getting precedence flag: %w", err)
+		}
+
+		if precedence, err = resolvePrecedence(precedence); err != nil {
+			return err
+		}
+
+		configLabels := map[string]string{}
+		for k, v := range cfg.Config.Labels {
+			configLabels[k] = v
+		}
+		for k, v := range cfg.Annotations {
+			configLabels[k] = v
+		}
+
+		sources := map[string]map[string]string{
+			ConfigSource:   configLabels,
+			ManifestSource: m.Manifest.Annotations,
+		}
+		if m.Index != nil {
+			sources[IndexSource] = m.Index.Annotations
+		}
+
+		l := mergeLabels(sources, precedence)
+		if len(l) == 0 {
 			return errors.New("no labels found")
 		}
 
@@ -86,11 +117,17 @@ var RootCmd = &cobra.Command{
 				return fmt.Errorf("writing to stdout: %w", err)
 			}
 		default:
+			keys := make([]string, 0, len(l))
+			for k := range l {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
 			t := table.NewWriter()
 			t.SetOutputMirror(cmd.OutOrStdout())
-			t.AppendHeader(table.Row{"Key", "Value"})
-			for k, v := range l {
-				t.AppendRow(table.Row{k, text.WrapSoft(v, 60)})
+			t.AppendHeader(table.Row{"Key", "Value", "Source"})
+			for _, k := range keys {
+				t.AppendRow(table.Row{k, text.WrapSoft(l[k].Value, 60), l[k].Source})
 			}
 			t.Render()
 		}
@@ -98,3 +135,57 @@ var RootCmd = &cobra.Command{
 		return nil
 	},
 }
+
+const (
+	ConfigSource   = "config"
+	ManifestSource = "manifest"
+	IndexSource    = "index"
+)
+
+var defaultPrecedence = []string{ConfigSource, ManifestSource, IndexSource}
+
+// Label is the value of a label along with where it was found
+type Label struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// resolvePrecedence validates the given precedence and appends the sources missing
+// in it in the default order.
+func resolvePrecedence(precedence []string) ([]string, error) {
+	resolved := make([]string, 0, len(defaultPrecedence))
+	seen := map[string]bool{}
+
+	for _, source := range precedence {
+		if !slices.Contains(defaultPrecedence, source) {
+			return nil, fmt.Errorf("unknown label source %q, expected one of %v", source, defaultPrecedence)
+		}
+
+		if !seen[source] {
+			seen[source] = true
+			resolved = append(resolved, source)
+		}
+	}
+
+	for _, source := range defaultPrecedence {
+		if !seen[source] {
+			resolved = append(resolved, source)
+		}
+	}
+
+	return resolved, nil
+}
+
+// mergeLabels merges the labels from all sources, when a key is found in more than one
+// source the value from the source that comes first in the precedence wins.
+func mergeLabels(sources map[string]map[string]string, precedence []string) map[string]Label {
+	merged := map[string]Label{}
+
+	for i := len(precedence) - 1; i >= 0; i-- {
+		for k, v := range sources[precedence[i]] {
+			merged[k] = Label{Value: v, Source: precedence[i]}
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,67 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePrecedence(t *testing.T) {
+	tests := []struct {
+		name               string
+		precedence         []string
+		expectedPrecedence []string
+		expectErr          bool
+	}{
+		{
+			name:               "default precedence",
+			precedence:         defaultPrecedence,
+			expectedPrecedence: []string{ConfigSource, ManifestSource, IndexSource},
+		},
+		{
+			name:               "partial precedence",
+			precedence:         []string{IndexSource},
+			expectedPrecedence: []string{IndexSource, ConfigSource, ManifestSource},
+		},
+		{
+			name:               "duplicated source",
+			precedence:         []string{ManifestSource, ManifestSource},
+			expectedPrecedence: []string{ManifestSource, ConfigSource, IndexSource},
+		},
+		{
+			name:       "unknown source",
+			precedence: []string{"layer"},
+			expectErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			precedence, err := resolvePrecedence(tt.precedence)
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedPrecedence, precedence)
+			}
+		})
+	}
+}
+
+func TestMergeLabels(t *testing.T) {
+	sources := map[string]map[string]string{
+		ConfigSource:   {"maintainer": "config", "version": "1.0"},
+		ManifestSource: {"maintainer": "manifest", "org.opencontainers.image.created": "2023-10-01T12:00:00Z"},
+		IndexSource:    {"maintainer": "index", "org.opencontainers.image.description": "nginx"},
+	}
+
+	require.Equal(t, map[string]Label{
+		"maintainer":                           {Value: "config", Source: ConfigSource},
+		"version":                              {Value: "1.0", Source: ConfigSource},
+		"org.opencontainers.image.created":     {Value: "2023-10-01T12:00:00Z", Source: ManifestSource},
+		"org.opencontainers.image.description": {Value: "nginx", Source: IndexSource},
+	}, mergeLabels(sources, defaultPrecedence))
+
+	require.Equal(t, Label{Value: "index", Source: IndexSource}, mergeLabels(sources, []string{IndexSource, ManifestSource, ConfigSource})["maintainer"])
+	require.Empty(t, mergeLabels(map[string]map[string]string{}, defaultPrecedence))
+}
@@ -0,0 +1,238 @@
+package ls
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jcchavezs/nuro/internal/layer"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+)
+
+var Formats = map[OutputFormat][]string{
+	Table: {"table"},
+	JSON:  {"json"},
+}
+
+type OutputFormat int
+
+const (
+	Table OutputFormat = iota
+	JSON
+)
+
+var outputFormat OutputFormat = Table
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&outputFormat, "string", Formats, enumflag.EnumCaseInsensitive),
+		"output",
+		"Sets the output format",
+	)
+	RootCmd.Flags().String("platform", "", "Lists the files of the image for the given platform (e.g. linux/amd64)")
+	RootCmd.Flags().Bool("recursive", false, "Lists the subdirectories recursively")
+}
+
+var RootCmd = &cobra.Command{
+	Use:   "ls <image> [path]",
+	Short: "Lists the files in a given image without pulling it",
+	Long: "Lists the files in a given image by merging its layers, showing the layer every file comes from. " +
+		"Symlinks in the path are followed, a symlink at the end of the path only when it ends with a slash.",
+	Example: "$ nuro ls alpine:3.18 /etc --recursive",
+	Args:    cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		p := "/"
+		if len(args) == 2 {
+			p = args[1]
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		m, err := manifest.Resolve(ctx, registry, insecure, name, reference, platform)
+		if err != nil {
+			return fmt.Errorf("resolving manifest: %w", err)
+		}
+
+		manifest.WarnSchema1(cmd, m)
+
+		fs, err := layer.Merge(ctx, registry, insecure, name, m.Manifest.Layers)
+		if err != nil {
+			return fmt.Errorf("reading layers: %w", err)
+		}
+
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		entries, err := fs.List(p, recursive, strings.HasSuffix(p, "/"))
+		if err != nil {
+			return fmt.Errorf("listing files: %w", err)
+		}
+
+		switch outputFormat {
+		case JSON:
+			files := make([]file, 0, len(entries))
+			for _, e := range entries {
+				files = append(files, newFile(e))
+			}
+
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(files); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		default:
+			t := table.NewWriter()
+			t.SetOutputMirror(cmd.OutOrStdout())
+			t.AppendHeader(table.Row{"Mode", "Owner", "Size", "Modified", "Path", "Layer"})
+			for _, e := range entries {
+				t.AppendRow(table.Row{
+					formatMode(e.Type, e.Mode),
+					formatOwner(e),
+					e.Size,
+					e.ModTime.UTC().Format(time.RFC3339),
+					formatPath(e),
+					shortDigest(e.Layer),
+				})
+			}
+			t.Render()
+		}
+
+		return nil
+	},
+}
+
+type file struct {
+	Path     string     `json:"path"`
+	Type     layer.Type `json:"type"`
+	Mode     string     `json:"mode"`
+	UID      int        `json:"uid"`
+	GID      int        `json:"gid"`
+	User     string     `json:"user,omitempty"`
+	Group    string     `json:"group,omitempty"`
+	Size     int64      `json:"size"`
+	ModTime  time.Time  `json:"modTime"`
+	Linkname string     `json:"linkname,omitempty"`
+	Layer    string     `json:"layer,omitempty"`
+}
+
+func newFile(e *layer.Entry) file {
+	return file{
+		Path:     e.Path,
+		Type:     e.Type,
+		Mode:     fmt.Sprintf("%04o", e.Mode),
+		UID:      e.UID,
+		GID:      e.GID,
+		User:     e.User,
+		Group:    e.Group,
+		Size:     e.Size,
+		ModTime:  e.ModTime.UTC(),
+		Linkname: e.Linkname,
+		Layer:    e.Layer,
+	}
+}
+
+var typeChars = map[layer.Type]byte{
+	layer.TypeDir:     'd',
+	layer.TypeSymlink: 'l',
+	layer.TypeChar:    'c',
+	layer.TypeBlock:   'b',
+	layer.TypeFifo:    'p',
+}
+
+// formatMode formats the mode as ls -l does, e.g. drwxr-xr-x
+func formatMode(t layer.Type, mode int64) string {
+	b := []byte("----------")
+	if c, ok := typeChars[t]; ok {
+		b[0] = c
+	}
+
+	const rwx = "rwxrwxrwx"
+	for i := 0; i < 9; i++ {
+		if mode&(1<<(8-i)) != 0 {
+			b[i+1] = rwx[i]
+		}
+	}
+
+	special := []struct {
+		bit  int64
+		pos  int
+		char byte
+	}{
+		{0o4000, 3, 's'},
+		{0o2000, 6, 's'},
+		{0o1000, 9, 't'},
+	}
+	for _, s := range special {
+		if mode&s.bit == 0 {
+			continue
+		}
+
+		if b[s.pos] == '-' {
+			// set without the execute bit
+			b[s.pos] = s.char - 'a' + 'A'
+		} else {
+			b[s.pos] = s.char
+		}
+	}
+
+	return string(b)
+}
+
+func formatOwner(e *layer.Entry) string {
+	user, group := e.User, e.Group
+	if user == "" {
+		user = fmt.Sprint(e.UID)
+	}
+
+	if group == "" {
+		group = fmt.Sprint(e.GID)
+	}
+
+	return user + ":" + group
+}
+
+func formatPath(e *layer.Entry) string {
+	switch e.Type {
+	case layer.TypeSymlink:
+		return e.Path + " -> " + e.Linkname
+	case layer.TypeHardlink:
+		return e.Path + " link to " + e.Linkname
+	}
+
+	return e.Path
+}
+
+// shortDigest returns the first 12 characters of the hex part of the digest
+func shortDigest(digest string) string {
+	_, hex, _ := strings.Cut(digest, ":")
+	if len(hex) > 12 {
+		return hex[:12]
+	}
+
+	return hex
+}
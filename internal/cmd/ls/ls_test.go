@@ -0,0 +1,43 @@
+package ls
+
+import (
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/layer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatMode(t *testing.T) {
+	tests := []struct {
+		typ      layer.Type
+		mode     int64
+		expected string
+	}{
+		{layer.TypeFile, 0o644, "-rw-r--r--"},
+		{layer.TypeDir, 0o755, "drwxr-xr-x"},
+		{layer.TypeSymlink, 0o777, "lrwxrwxrwx"},
+		{layer.TypeHardlink, 0o600, "-rw-------"},
+		{layer.TypeFile, 0o4755, "-rwsr-xr-x"},
+		{layer.TypeFile, 0o2644, "-rw-r-Sr--"},
+		{layer.TypeDir, 0o1777, "drwxrwxrwt"},
+		{layer.TypeDir, 0o1770, "drwxrwx--T"},
+		{layer.TypeChar, 0o666, "crw-rw-rw-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			require.Equal(t, tt.expected, formatMode(tt.typ, tt.mode))
+		})
+	}
+}
+
+func TestFormatPath(t *testing.T) {
+	require.Equal(t, "/etc/passwd", formatPath(&layer.Entry{Path: "/etc/passwd", Type: layer.TypeFile}))
+	require.Equal(t, "/bin -> usr/bin", formatPath(&layer.Entry{Path: "/bin", Type: layer.TypeSymlink, Linkname: "usr/bin"}))
+	require.Equal(t, "/bin/ls link to /bin/busybox", formatPath(&layer.Entry{Path: "/bin/ls", Type: layer.TypeHardlink, Linkname: "/bin/busybox"}))
+}
+
+func TestShortDigest(t *testing.T) {
+	require.Equal(t, "463e52fb0610", shortDigest("sha256:463e52fb06104b9a76fd6f7bce280e11a7f06b6a7707112f626e01414027d96c"))
+	require.Equal(t, "", shortDigest(""))
+}
@@ -0,0 +1,119 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Bool("raw", false, "Prints the manifest exactly as served by the registry")
+	RootCmd.Flags().Bool("resolve", false, "Walks an index into the image manifest for the chosen platform")
+	RootCmd.Flags().String("platform", "", "Resolves the index into the manifest for the platform (e.g. linux/amd64), implies --resolve")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "manifest <image>",
+	Short:   "Shows the manifest for a given image",
+	Example: "$ nuro manifest alpine --platform linux/arm64",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		res, err := manifest.Get(ctx, registry, insecure, name, reference)
+		if err != nil {
+			return fmt.Errorf("getting manifest: %w", err)
+		}
+
+		if platform != nil && !res.IsIndex() {
+			return fmt.Errorf("image is not multi-platform, manifest has media type %q", res.MediaType)
+		}
+
+		var indexDigest string
+		if resolve, _ := cmd.Flags().GetBool("resolve"); (resolve || platform != nil) && res.IsIndex() {
+			idx, err := res.Index()
+			if err != nil {
+				return fmt.Errorf("getting index: %w", err)
+			}
+
+			d, err := manifest.SelectManifest(idx, platform)
+			if err != nil {
+				return err
+			}
+
+			indexDigest = res.Digest
+			if res, err = manifest.GetByDescriptor(ctx, registry, insecure, name, d); err != nil {
+				return fmt.Errorf("getting child manifest: %w", err)
+			}
+		}
+
+		if raw, _ := cmd.Flags().GetBool("raw"); raw {
+			if _, err := cmd.OutOrStdout().Write(res.Body); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+
+			return nil
+		}
+
+		if err := printManifest(cmd.OutOrStdout(), image.FormatReference(registry, name, tag, digest), indexDigest, res); err != nil {
+			return fmt.Errorf("writing to stdout: %w", err)
+		}
+
+		return nil
+	},
+}
+
+// printManifest prints the manifest indented and preceded by its media type and digest
+func printManifest(w io.Writer, reference, indexDigest string, res *manifest.Response) error {
+	fmt.Fprintf(w, "Reference:    %s\n", reference)
+	if indexDigest != "" {
+		fmt.Fprintf(w, "Index digest: %s\n", indexDigest)
+	}
+	fmt.Fprintf(w, "Media type:   %s\n", res.MediaType)
+	fmt.Fprintf(w, "Digest:       %s\n", res.Digest)
+	fmt.Fprintf(w, "Size:         %d\n", len(res.Body))
+	if res.IsSchema1() {
+		fmt.Fprintln(w, "Deprecated:   image uses the docker schema1 manifest format")
+	}
+	fmt.Fprintln(w)
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, res.Body, "", "  "); err != nil {
+		// not every manifest is valid JSON, in such case it is printed as is
+		out.Reset()
+		out.Write(res.Body)
+	}
+	out.WriteByte('\n')
+
+	_, err := out.WriteTo(w)
+	return err
+}
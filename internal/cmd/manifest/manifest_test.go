@@ -0,0 +1,55 @@
+package manifest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintManifest(t *testing.T) {
+	res := &manifest.Response{
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Digest:    "sha256:abc123",
+		Body:      []byte(`{"schemaVersion":2}`),
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, printManifest(&out, "docker.io/library/nginx:latest", "sha256:def456", res))
+	require.Equal(t, `Reference:    docker.io/library/nginx:latest
+Index digest: sha256:def456
+Media type:   application/vnd.oci.image.manifest.v1+json
+Digest:       sha256:abc123
+Size:         19
+
+{
+  "schemaVersion": 2
+}
+`, out.String())
+}
+
+func TestManifestPlatform(t *testing.T) {
+	reg := registrytest.New(t)
+
+	amd64 := reg.PutImage(t, []byte(`{"architecture":"amd64","os":"linux"}`), nil, "single")
+	amd64.Platform = &manifest.Platform{OS: "linux", Architecture: "amd64"}
+
+	reg.PutManifest(t, manifest.Index{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIIndexV1ContentType,
+		Manifests:     []manifest.Descriptor{amd64},
+	}, "latest")
+
+	t.Run("index", func(t *testing.T) {
+		out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:latest", "--insecure", "--platform", "linux/amd64")
+		require.NoError(t, err)
+		require.Contains(t, out, "Digest:       "+amd64.Digest+"\n")
+	})
+
+	t.Run("single manifest", func(t *testing.T) {
+		_, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:single", "--insecure", "--platform", "linux/amd64")
+		require.ErrorContains(t, err, "image is not multi-platform")
+	})
+}
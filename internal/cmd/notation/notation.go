@@ -0,0 +1,157 @@
+package notation
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jcchavezs/nuro/internal/notation"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+)
+
+var Formats = map[OutputFormat][]string{
+	Table: {"table"},
+	JSON:  {"json"},
+}
+
+type OutputFormat int
+
+const (
+	Table OutputFormat = iota
+	JSON
+)
+
+var outputFormat OutputFormat = Table
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&outputFormat, "string", Formats, enumflag.EnumCaseInsensitive),
+		"output",
+		"Sets the output format",
+	)
+	RootCmd.Flags().String("trust-store", "", "Directory with the trusted X.509 certificates used to verify the signatures")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "notation <image>",
+	Short:   "Lists the notation signatures of a given image and optionally verifies them",
+	Example: "$ nuro notation ghcr.io/org/app:v1.0.0 --trust-store ~/.config/notation/truststore",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		trustStore, _ := cmd.Flags().GetString("trust-store")
+		verify := trustStore != ""
+
+		var roots *x509.CertPool
+		if verify {
+			if roots, err = notation.LoadTrustStore(trustStore); err != nil {
+				return err
+			}
+		}
+
+		if digest == "" {
+			d, err := manifest.Head(ctx, registry, insecure, name, tag)
+			if err != nil {
+				return fmt.Errorf("getting manifest descriptor: %w", err)
+			}
+
+			digest = d.Digest
+		}
+
+		signatures, err := notation.Find(ctx, registry, insecure, name, digest)
+		if err != nil {
+			return fmt.Errorf("finding signatures: %w", err)
+		}
+
+		if len(signatures) == 0 {
+			return fmt.Errorf("no notation signatures found for %s", digest)
+		}
+
+		results := make([]result, 0, len(signatures))
+		verified := 0
+		for _, s := range signatures {
+			r := result{
+				Digest:       s.Digest,
+				Envelope:     s.MediaType,
+				SigningTime:  s.SigningTime,
+				Subject:      s.Subject(),
+				SignedDigest: s.Target.Digest,
+			}
+
+			if verify {
+				v := true
+				if err := s.Verify(roots, digest); err != nil {
+					v = false
+					r.Error = err.Error()
+				} else {
+					verified++
+				}
+				r.Verified = &v
+			}
+
+			results = append(results, r)
+		}
+
+		switch outputFormat {
+		case JSON:
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(results); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		default:
+			t := table.NewWriter()
+			t.SetOutputMirror(cmd.OutOrStdout())
+			header := table.Row{"Digest", "Envelope", "Signing Time", "Certificate Subject", "Signed Digest"}
+			if verify {
+				header = append(header, "Verified")
+			}
+			t.AppendHeader(header)
+			for _, r := range results {
+				row := table.Row{r.Digest, r.Envelope, r.SigningTime.Format(time.RFC3339), r.Subject, r.SignedDigest}
+				if verify {
+					status := "yes"
+					if !*r.Verified {
+						status = "no: " + r.Error
+					}
+					row = append(row, status)
+				}
+				t.AppendRow(row)
+			}
+			t.Render()
+		}
+
+		if verify && verified == 0 {
+			return errors.New("no valid signatures found")
+		}
+
+		return nil
+	},
+}
+
+type result struct {
+	Digest       string    `json:"digest"`
+	Envelope     string    `json:"envelope"`
+	SigningTime  time.Time `json:"signingTime"`
+	Subject      string    `json:"certificateSubject"`
+	SignedDigest string    `json:"signedDigest"`
+	Verified     *bool     `json:"verified,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
@@ -0,0 +1,173 @@
+package notation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/notation"
+	"github.com/jcchavezs/nuro/internal/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+var signingTime = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// newCertificate creates a certificate for the key, self-signed and acting as a CA when
+// there is no parent
+func newCertificate(t *testing.T, cn string, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    signingTime.Add(-time.Hour),
+		NotAfter:     signingTime.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+
+	c, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return c
+}
+
+// writeTrustStore writes the certificate into a trust store directory
+func writeTrustStore(t *testing.T, c *x509.Certificate) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}), 0o600))
+
+	return dir
+}
+
+// jwsEnvelope signs the target with ES256 and returns the JWS envelope
+func jwsEnvelope(t *testing.T, key *ecdsa.PrivateKey, chain []*x509.Certificate, target manifest.Descriptor) []byte {
+	protected, err := json.Marshal(map[string]any{
+		"alg":                        "ES256",
+		"cty":                        "application/vnd.cncf.notary.payload.v1+json",
+		"crit":                       []string{"io.cncf.notary.signingScheme"},
+		"io.cncf.notary.signingTime": signingTime.Format(time.RFC3339),
+	})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(map[string]any{"targetArtifact": target})
+	require.NoError(t, err)
+
+	input := base64.RawURLEncoding.EncodeToString(protected) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hashed := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hashed[:])
+	require.NoError(t, err)
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	x5c := make([][]byte, 0, len(chain))
+	for _, c := range chain {
+		x5c = append(x5c, c.Raw)
+	}
+
+	b, err := json.Marshal(map[string]any{
+		"protected": base64.RawURLEncoding.EncodeToString(protected),
+		"payload":   base64.RawURLEncoding.EncodeToString(payload),
+		"header":    map[string]any{"x5c": x5c},
+		"signature": base64.RawURLEncoding.EncodeToString(sig),
+	})
+	require.NoError(t, err)
+
+	return b
+}
+
+func TestNotation(t *testing.T) {
+	reg := registrytest.New(t)
+	reg.Referrers = true
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	root := newCertificate(t, "root", rootKey, nil, nil)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leaf := newCertificate(t, "signer", leafKey, root, rootKey)
+
+	subject := reg.PutImage(t, []byte(`{"architecture":"amd64","os":"linux"}`), nil, "signed")
+	sig := reg.PutManifest(t, manifest.Manifest{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIManifestV1ContentType,
+		ArtifactType:  notation.ArtifactType,
+		Config:        reg.PutBlob([]byte("{}"), manifest.OCIEmptyContentType),
+		Layers:        []manifest.Descriptor{reg.PutBlob(jwsEnvelope(t, leafKey, []*x509.Certificate{leaf, root}, subject), notation.JWSMediaType)},
+		Subject:       &subject,
+	})
+
+	run := func(t *testing.T, ref string, args ...string) ([]result, error) {
+		out, err := registrytest.Execute(t, RootCmd, append([]string{reg.Host() + "/org/app" + ref, "--insecure", "--output", "json"}, args...)...)
+
+		var results []result
+		if out != "" {
+			require.NoError(t, json.Unmarshal([]byte(out), &results))
+		}
+
+		return results, err
+	}
+
+	t.Run("list", func(t *testing.T) {
+		results, err := run(t, ":signed")
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, sig.Digest, results[0].Digest)
+		require.Equal(t, notation.JWSMediaType, results[0].Envelope)
+		require.Equal(t, "CN=signer", results[0].Subject)
+		require.Equal(t, subject.Digest, results[0].SignedDigest)
+		require.True(t, signingTime.Equal(results[0].SigningTime))
+		require.Nil(t, results[0].Verified)
+	})
+
+	t.Run("trusted", func(t *testing.T) {
+		results, err := run(t, "@"+subject.Digest, "--trust-store", writeTrustStore(t, root))
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.NotNil(t, results[0].Verified)
+		require.True(t, *results[0].Verified)
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		results, err := run(t, ":signed", "--trust-store", writeTrustStore(t, newCertificate(t, "other", otherKey, nil, nil)))
+		require.EqualError(t, err, "no valid signatures found")
+		require.Len(t, results, 1)
+		require.NotNil(t, results[0].Verified)
+		require.False(t, *results[0].Verified)
+		require.Contains(t, results[0].Error, "verifying certificate chain")
+	})
+
+	t.Run("no signatures", func(t *testing.T) {
+		reg.PutImage(t, []byte(`{"architecture":"arm64","os":"linux"}`), nil, "unsigned")
+
+		_, err := run(t, ":unsigned")
+		require.ErrorContains(t, err, "no notation signatures found")
+	})
+}
@@ -0,0 +1,157 @@
+package provenance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/attestation"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/jedib0t/go-pretty/text"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+)
+
+var Formats = map[OutputFormat][]string{
+	Table: {"table"},
+	JSON:  {"json"},
+}
+
+type OutputFormat int
+
+const (
+	Table OutputFormat = iota
+	JSON
+)
+
+var outputFormat OutputFormat = Table
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&outputFormat, "string", Formats, enumflag.EnumCaseInsensitive),
+		"output",
+		"Sets the output format",
+	)
+	RootCmd.Flags().String("platform", "", "Platform of the image (e.g. linux/amd64), defaults to the first manifest")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "provenance <image>",
+	Short:   "Shows the SLSA provenance attached to a given image",
+	Example: "$ nuro provenance docker/dockerfile:1 --platform linux/amd64",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		m, err := manifest.Resolve(ctx, registry, insecure, name, reference, platform)
+		if err != nil {
+			return fmt.Errorf("resolving manifest: %w", err)
+		}
+
+		statements, err := attestation.Statements(ctx, registry, insecure, name, m.Index, m.Digest, isProvenance)
+		if err != nil {
+			return fmt.Errorf("getting provenance attestations: %w", err)
+		}
+
+		if len(statements) == 0 {
+			return errors.New("no provenance found")
+		}
+
+		provenances := make([]*Provenance, 0, len(statements))
+		for _, s := range statements {
+			p, err := parseProvenance(s.PredicateType, s.Predicate)
+			if err != nil {
+				return err
+			}
+
+			provenances = append(provenances, p)
+		}
+
+		switch outputFormat {
+		case JSON:
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(provenances); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		default:
+			for _, p := range provenances {
+				printProvenance(cmd.OutOrStdout(), p)
+			}
+		}
+
+		return nil
+	},
+}
+
+func printProvenance(w io.Writer, p *Provenance) {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.AppendRow(table.Row{"Predicate Type", p.PredicateType})
+	t.AppendRow(table.Row{"Builder", p.BuilderID})
+	t.AppendRow(table.Row{"Build Type", p.BuildType})
+	if p.Source != nil {
+		t.AppendRow(table.Row{"Source", p.Source.URI})
+		t.AppendRow(table.Row{"Source Digest", p.Source.digests()})
+	}
+	if p.StartedOn != nil {
+		t.AppendRow(table.Row{"Started On", p.StartedOn.Format(time.RFC3339)})
+	}
+	if p.FinishedOn != nil {
+		t.AppendRow(table.Row{"Finished On", p.FinishedOn.Format(time.RFC3339)})
+	}
+	t.Render()
+
+	if len(p.Materials) > 0 {
+		t := table.NewWriter()
+		t.SetOutputMirror(w)
+		t.AppendHeader(table.Row{"Material", "Digest"})
+		for _, m := range p.Materials {
+			t.AppendRow(table.Row{text.WrapHard(m.URI, 80), m.digests()})
+		}
+		t.Render()
+	}
+
+	if len(p.BuildArgs) > 0 {
+		keys := make([]string, 0, len(p.BuildArgs))
+		for k := range p.BuildArgs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		t := table.NewWriter()
+		t.SetOutputMirror(w)
+		t.AppendHeader(table.Row{"Build Arg", "Value"})
+		for _, k := range keys {
+			t.AppendRow(table.Row{k, text.WrapSoft(p.BuildArgs[k], 60)})
+		}
+		t.Render()
+	}
+}
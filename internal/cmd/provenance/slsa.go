@@ -0,0 +1,152 @@
+package provenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	slsaV02PredicateType = "https://slsa.dev/provenance/v0.2"
+	slsaV1PredicateType  = "https://slsa.dev/provenance/v1"
+
+	buildArgPrefix = "build-arg:"
+)
+
+func isProvenance(predicateType string) bool {
+	return predicateType == slsaV02PredicateType || predicateType == slsaV1PredicateType
+}
+
+// Material is an artifact the build consumed
+type Material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+func (m Material) digests() string {
+	digests := make([]string, 0, len(m.Digest))
+	for alg, d := range m.Digest {
+		digests = append(digests, alg+":"+d)
+	}
+	sort.Strings(digests)
+
+	return strings.Join(digests, "\n")
+}
+
+// Provenance is the SLSA provenance of an image normalized across predicate versions
+type Provenance struct {
+	PredicateType string            `json:"predicateType"`
+	BuilderID     string            `json:"builderId"`
+	BuildType     string            `json:"buildType"`
+	Source        *Material         `json:"source,omitempty"`
+	Materials     []Material        `json:"materials"`
+	BuildArgs     map[string]string `json:"buildArgs,omitempty"`
+	StartedOn     *time.Time        `json:"startedOn,omitempty"`
+	FinishedOn    *time.Time        `json:"finishedOn,omitempty"`
+}
+
+type slsaV02 struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	BuildType  string     `json:"buildType"`
+	Materials  []Material `json:"materials"`
+	Invocation struct {
+		ConfigSource struct {
+			URI    string            `json:"uri"`
+			Digest map[string]string `json:"digest"`
+		} `json:"configSource"`
+		Parameters struct {
+			Args map[string]string `json:"args"`
+		} `json:"parameters"`
+	} `json:"invocation"`
+	Metadata struct {
+		BuildStartedOn  *time.Time `json:"buildStartedOn"`
+		BuildFinishedOn *time.Time `json:"buildFinishedOn"`
+	} `json:"metadata"`
+}
+
+type slsaV1 struct {
+	BuildDefinition struct {
+		BuildType          string `json:"buildType"`
+		ExternalParameters struct {
+			ConfigSource struct {
+				URI    string            `json:"uri"`
+				Digest map[string]string `json:"digest"`
+			} `json:"configSource"`
+			Request struct {
+				Args map[string]string `json:"args"`
+			} `json:"request"`
+		} `json:"externalParameters"`
+		ResolvedDependencies []Material `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Metadata struct {
+			StartedOn  *time.Time `json:"startedOn"`
+			FinishedOn *time.Time `json:"finishedOn"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// parseProvenance normalizes a SLSA v0.2 or v1 provenance predicate
+func parseProvenance(predicateType string, predicate []byte) (*Provenance, error) {
+	p := &Provenance{PredicateType: predicateType}
+
+	switch predicateType {
+	case slsaV02PredicateType:
+		var v slsaV02
+		if err := json.Unmarshal(predicate, &v); err != nil {
+			return nil, fmt.Errorf("decoding provenance: %w", err)
+		}
+
+		p.BuilderID = v.Builder.ID
+		p.BuildType = v.BuildType
+		p.Materials = v.Materials
+		p.BuildArgs = buildArgs(v.Invocation.Parameters.Args)
+		p.StartedOn = v.Metadata.BuildStartedOn
+		p.FinishedOn = v.Metadata.BuildFinishedOn
+		if v.Invocation.ConfigSource.URI != "" {
+			p.Source = &Material{URI: v.Invocation.ConfigSource.URI, Digest: v.Invocation.ConfigSource.Digest}
+		}
+	case slsaV1PredicateType:
+		var v slsaV1
+		if err := json.Unmarshal(predicate, &v); err != nil {
+			return nil, fmt.Errorf("decoding provenance: %w", err)
+		}
+
+		p.BuilderID = v.RunDetails.Builder.ID
+		p.BuildType = v.BuildDefinition.BuildType
+		p.Materials = v.BuildDefinition.ResolvedDependencies
+		p.BuildArgs = buildArgs(v.BuildDefinition.ExternalParameters.Request.Args)
+		p.StartedOn = v.RunDetails.Metadata.StartedOn
+		p.FinishedOn = v.RunDetails.Metadata.FinishedOn
+		if cs := v.BuildDefinition.ExternalParameters.ConfigSource; cs.URI != "" {
+			p.Source = &Material{URI: cs.URI, Digest: cs.Digest}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported predicate type %q", predicateType)
+	}
+
+	if p.Materials == nil {
+		p.Materials = []Material{}
+	}
+
+	return p, nil
+}
+
+// buildArgs extracts the build args out of the BuildKit frontend args
+func buildArgs(args map[string]string) map[string]string {
+	buildArgs := map[string]string{}
+	for k, v := range args {
+		if name, ok := strings.CutPrefix(k, buildArgPrefix); ok {
+			buildArgs[name] = v
+		}
+	}
+
+	return buildArgs
+}
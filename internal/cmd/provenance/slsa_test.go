@@ -0,0 +1,92 @@
+package provenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProvenance(t *testing.T) {
+	startedOn := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	finishedOn := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expected := &Provenance{
+		BuilderID: "https://github.com/org/app/actions/runs/1",
+		BuildType: "https://mobyproject.org/buildkit@v1",
+		Source: &Material{
+			URI:    "https://github.com/org/app.git#refs/heads/main",
+			Digest: map[string]string{"sha1": "0123456789abcdef0123456789abcdef01234567"},
+		},
+		Materials: []Material{
+			{URI: "pkg:docker/alpine@3.20", Digest: map[string]string{"sha256": "abc123"}},
+		},
+		BuildArgs:  map[string]string{"VERSION": "1.0.0"},
+		StartedOn:  &startedOn,
+		FinishedOn: &finishedOn,
+	}
+
+	tests := []struct {
+		name          string
+		predicateType string
+		predicate     string
+		expectErr     bool
+	}{
+		{
+			name:          "SLSA v0.2",
+			predicateType: slsaV02PredicateType,
+			predicate: `{
+				"builder": {"id": "https://github.com/org/app/actions/runs/1"},
+				"buildType": "https://mobyproject.org/buildkit@v1",
+				"materials": [{"uri": "pkg:docker/alpine@3.20", "digest": {"sha256": "abc123"}}],
+				"invocation": {
+					"configSource": {"uri": "https://github.com/org/app.git#refs/heads/main", "digest": {"sha1": "0123456789abcdef0123456789abcdef01234567"}},
+					"parameters": {"frontend": "dockerfile.v0", "args": {"build-arg:VERSION": "1.0.0", "label:maintainer": "org"}}
+				},
+				"metadata": {"buildStartedOn": "2024-01-02T03:00:00Z", "buildFinishedOn": "2024-01-02T03:04:05Z"}
+			}`,
+		},
+		{
+			name:          "SLSA v1",
+			predicateType: slsaV1PredicateType,
+			predicate: `{
+				"buildDefinition": {
+					"buildType": "https://mobyproject.org/buildkit@v1",
+					"externalParameters": {
+						"configSource": {"uri": "https://github.com/org/app.git#refs/heads/main", "digest": {"sha1": "0123456789abcdef0123456789abcdef01234567"}},
+						"request": {"frontend": "dockerfile.v0", "args": {"build-arg:VERSION": "1.0.0"}}
+					},
+					"resolvedDependencies": [{"uri": "pkg:docker/alpine@3.20", "digest": {"sha256": "abc123"}}]
+				},
+				"runDetails": {
+					"builder": {"id": "https://github.com/org/app/actions/runs/1"},
+					"metadata": {"startedOn": "2024-01-02T03:00:00Z", "finishedOn": "2024-01-02T03:04:05Z"}
+				}
+			}`,
+		},
+		{
+			name:          "unsupported predicate type",
+			predicateType: "https://spdx.dev/Document",
+			predicate:     `{}`,
+			expectErr:     true,
+		},
+		{
+			name:          "invalid predicate",
+			predicateType: slsaV1PredicateType,
+			predicate:     `[]`,
+			expectErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseProvenance(tt.predicateType, []byte(tt.predicate))
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				expected.PredicateType = tt.predicateType
+				require.Equal(t, expected, p)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package referrers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/api/referrers"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/jedib0t/go-pretty/text"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+)
+
+var Formats = map[OutputFormat][]string{
+	Table: {"table"},
+	JSON:  {"json"},
+}
+
+type OutputFormat int
+
+const (
+	Table OutputFormat = iota
+	JSON
+)
+
+var outputFormat OutputFormat = Table
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&outputFormat, "string", Formats, enumflag.EnumCaseInsensitive),
+		"output",
+		"Sets the output format",
+	)
+	RootCmd.Flags().String("artifact-type", "", "Only lists referrers with the given artifact type")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "referrers <image>",
+	Short:   "Lists the artifacts referring to a given image, e.g. signatures or SBOMs",
+	Example: "$ nuro referrers ghcr.io/org/app:v1.0.0 --artifact-type application/spdx+json",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		if digest == "" {
+			d, err := manifest.Head(ctx, registry, insecure, name, tag)
+			if err != nil {
+				return fmt.Errorf("getting manifest descriptor: %w", err)
+			}
+
+			digest = d.Digest
+		}
+
+		artifactType, _ := cmd.Flags().GetString("artifact-type")
+
+		descriptors, err := referrers.List(ctx, registry, insecure, name, digest, artifactType)
+		if err != nil {
+			return fmt.Errorf("listing referrers: %w", err)
+		}
+
+		switch outputFormat {
+		case JSON:
+			if descriptors == nil {
+				descriptors = []manifest.Descriptor{}
+			}
+
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(descriptors); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		default:
+			t := table.NewWriter()
+			t.SetOutputMirror(cmd.OutOrStdout())
+			t.AppendHeader(table.Row{"Artifact Type", "Digest", "Size", "Annotations"})
+			for _, d := range descriptors {
+				t.AppendRow(table.Row{d.ArtifactType, d.Digest, d.Size, formatAnnotations(d.Annotations)})
+			}
+			t.Render()
+		}
+
+		return nil
+	},
+}
+
+func formatAnnotations(annotations map[string]string) string {
+	lines := make([]string, 0, len(annotations))
+	for k, v := range annotations {
+		lines = append(lines, k+"="+text.WrapSoft(v, 60))
+	}
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,73 @@
+package referrers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/api/referrers"
+	"github.com/jcchavezs/nuro/internal/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferrers(t *testing.T) {
+	reg := registrytest.New(t)
+
+	subject := reg.PutImage(t, []byte(`{"architecture":"amd64","os":"linux"}`), nil, "latest")
+
+	artifact := func(artifactType string) manifest.Descriptor {
+		d := reg.PutManifest(t, manifest.Manifest{
+			SchemaVersion: 2,
+			MediaType:     manifest.OCIManifestV1ContentType,
+			ArtifactType:  artifactType,
+			Config:        reg.PutBlob([]byte("{}"), "application/vnd.oci.empty.v1+json"),
+			Layers:        []manifest.Descriptor{reg.PutBlob([]byte(artifactType), artifactType)},
+			Subject:       &subject,
+		})
+		d.ArtifactType = artifactType
+		return d
+	}
+
+	sbom := artifact("application/spdx+json")
+	sig := artifact("application/vnd.dev.cosign.artifact.sig.v1+json")
+
+	list := func(t *testing.T, args ...string) []manifest.Descriptor {
+		out, err := registrytest.Execute(t, RootCmd, append([]string{reg.Host() + "/org/app:latest", "--insecure", "--output", "json"}, args...)...)
+		require.NoError(t, err)
+
+		var descriptors []manifest.Descriptor
+		require.NoError(t, json.Unmarshal([]byte(out), &descriptors))
+		return descriptors
+	}
+
+	t.Run("referrers API", func(t *testing.T) {
+		reg.Referrers = true
+
+		require.ElementsMatch(t, []manifest.Descriptor{sbom, sig}, list(t))
+		require.Equal(t, []manifest.Descriptor{sbom}, list(t, "--artifact-type", "application/spdx+json"))
+		require.Equal(t, []manifest.Descriptor{}, list(t, "--artifact-type", "application/unknown"))
+	})
+
+	t.Run("tag schema", func(t *testing.T) {
+		reg.Referrers = false
+
+		require.Equal(t, []manifest.Descriptor{}, list(t))
+
+		reg.PutManifest(t, manifest.Index{
+			SchemaVersion: 2,
+			MediaType:     manifest.OCIIndexV1ContentType,
+			Manifests:     []manifest.Descriptor{sbom, sig},
+		}, referrers.TagSchemaTag(subject.Digest))
+
+		require.Equal(t, []manifest.Descriptor{sbom, sig}, list(t))
+		require.Equal(t, []manifest.Descriptor{sig}, list(t, "--artifact-type", "application/vnd.dev.cosign.artifact.sig.v1+json"))
+	})
+
+	t.Run("table", func(t *testing.T) {
+		out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app@"+subject.Digest, "--insecure")
+		require.NoError(t, err)
+		require.Contains(t, out, "ARTIFACT TYPE")
+		require.Contains(t, out, sbom.Digest)
+		require.Contains(t, out, sig.Digest)
+	})
+}
@@ -6,8 +6,29 @@ import (
 	"os"
 
 	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/bytesize"
+	"github.com/jcchavezs/nuro/internal/cache"
+	"github.com/jcchavezs/nuro/internal/cmd/artifact"
+	"github.com/jcchavezs/nuro/internal/cmd/attestations"
+	"github.com/jcchavezs/nuro/internal/cmd/blob"
+	cachecmd "github.com/jcchavezs/nuro/internal/cmd/cache"
+	"github.com/jcchavezs/nuro/internal/cmd/cat"
 	"github.com/jcchavezs/nuro/internal/cmd/created"
+	"github.com/jcchavezs/nuro/internal/cmd/digest"
+	"github.com/jcchavezs/nuro/internal/cmd/env"
+	"github.com/jcchavezs/nuro/internal/cmd/export"
+	"github.com/jcchavezs/nuro/internal/cmd/history"
+	"github.com/jcchavezs/nuro/internal/cmd/inspect"
 	"github.com/jcchavezs/nuro/internal/cmd/labels"
+	"github.com/jcchavezs/nuro/internal/cmd/ls"
+	"github.com/jcchavezs/nuro/internal/cmd/manifest"
+	"github.com/jcchavezs/nuro/internal/cmd/notation"
+	"github.com/jcchavezs/nuro/internal/cmd/provenance"
+	"github.com/jcchavezs/nuro/internal/cmd/referrers"
+	"github.com/jcchavezs/nuro/internal/cmd/runspec"
+	"github.com/jcchavezs/nuro/internal/cmd/sbom"
+	"github.com/jcchavezs/nuro/internal/cmd/sign"
+	"github.com/jcchavezs/nuro/internal/cmd/verify"
 	"github.com/jcchavezs/nuro/internal/log"
 
 	"github.com/spf13/cobra"
@@ -38,8 +59,34 @@ func init() {
 
 	RootCmd.MarkFlagsMutuallyExclusive("netrc-file", "netrc-stdin")
 
+	RootCmd.PersistentFlags().Bool("offline", false, "Serves every request from the cache, failing if the content is not cached")
+	RootCmd.PersistentFlags().Bool("no-cache", false, "Disables the on-disk cache of manifests and blobs")
+	RootCmd.PersistentFlags().Duration("cache-ttl", cache.DefaultTTL, "Sets for how long tag resolutions are served from the cache")
+	RootCmd.PersistentFlags().String("cache-max-size", cache.DefaultMaxSize, "Sets the maximum size of the cache, least recently used content is removed first when exceeded (e.g. 500MB, 2GiB), 0 disables the limit")
+
+	RootCmd.MarkFlagsMutuallyExclusive("offline", "no-cache")
+
+	RootCmd.AddCommand(artifact.RootCmd)
+	RootCmd.AddCommand(attestations.RootCmd)
+	RootCmd.AddCommand(blob.RootCmd)
+	RootCmd.AddCommand(cachecmd.RootCmd)
+	RootCmd.AddCommand(cat.RootCmd)
 	RootCmd.AddCommand(created.RootCmd)
+	RootCmd.AddCommand(digest.RootCmd)
+	RootCmd.AddCommand(env.RootCmd)
+	RootCmd.AddCommand(export.RootCmd)
+	RootCmd.AddCommand(history.RootCmd)
+	RootCmd.AddCommand(inspect.RootCmd)
 	RootCmd.AddCommand(labels.RootCmd)
+	RootCmd.AddCommand(ls.RootCmd)
+	RootCmd.AddCommand(manifest.RootCmd)
+	RootCmd.AddCommand(notation.RootCmd)
+	RootCmd.AddCommand(provenance.RootCmd)
+	RootCmd.AddCommand(referrers.RootCmd)
+	RootCmd.AddCommand(runspec.RootCmd)
+	RootCmd.AddCommand(sbom.RootCmd)
+	RootCmd.AddCommand(sign.RootCmd)
+	RootCmd.AddCommand(verify.RootCmd)
 }
 
 var RootCmd = &cobra.Command{
@@ -63,6 +110,23 @@ var RootCmd = &cobra.Command{
 			}
 		}
 
+		if noCache, _ := cmd.Flags().GetBool("no-cache"); !noCache {
+			dir, err := cache.DefaultDir()
+			if err != nil {
+				return err
+			}
+
+			s, _ := cmd.Flags().GetString("cache-max-size")
+			maxSize, err := bytesize.Parse(s)
+			if err != nil {
+				return fmt.Errorf("parsing cache max size: %w", err)
+			}
+
+			ttl, _ := cmd.Flags().GetDuration("cache-ttl")
+			offline, _ := cmd.Flags().GetBool("offline")
+			cache.Init(dir, ttl, maxSize, offline)
+		}
+
 		return nil
 	},
 	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
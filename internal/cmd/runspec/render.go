@@ -0,0 +1,443 @@
+package runspec
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+)
+
+// spec is the run configuration of the image normalized from its config
+type spec struct {
+	Name        string
+	Image       string
+	Entrypoint  []string
+	Cmd         []string
+	Env         []string
+	User        string
+	WorkingDir  string
+	Ports       []port
+	Volumes     []string
+	Healthcheck *blob.Healthcheck
+	// Warnings lists what could not be translated from the config
+	Warnings []string
+}
+
+type port struct {
+	Number   int
+	Protocol string
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// dnsName turns the value into a valid DNS-1123 label, as required by kubernetes names
+func dnsName(s string) string {
+	s = strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > 63 {
+		s = strings.TrimRight(s[:63], "-")
+	}
+
+	if s == "" {
+		return "app"
+	}
+
+	return s
+}
+
+// volumeNames returns a unique name for every volume, suffixing the names of the paths
+// which turn into the same label, e.g. /var/lib and /var-lib.
+func volumeNames(volumes []string) []string {
+	names := make([]string, 0, len(volumes))
+	used := map[string]bool{}
+	for _, v := range volumes {
+		name := dnsName(v)
+		for i := 2; used[name]; i++ {
+			suffix := "-" + strconv.Itoa(i)
+			base := dnsName(v)
+			if len(base)+len(suffix) > 63 {
+				base = strings.TrimRight(base[:63-len(suffix)], "-")
+			}
+			name = base + suffix
+		}
+
+		used[name] = true
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func newSpec(name, ref string, cfg *blob.ConfigBlob) spec {
+	s := spec{
+		Name:        dnsName(name[strings.LastIndex(name, "/")+1:]),
+		Image:       ref,
+		Entrypoint:  cfg.Config.Entrypoint,
+		Cmd:         cfg.Config.Cmd,
+		Env:         cfg.Config.Env,
+		User:        cfg.Config.User,
+		WorkingDir:  cfg.Config.WorkingDir,
+		Healthcheck: cfg.Config.Healthcheck,
+	}
+
+	for p := range cfg.Config.ExposedPorts {
+		number, protocol, _ := strings.Cut(p, "/")
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			s.Warnings = append(s.Warnings, fmt.Sprintf("ignoring exposed port %q", p))
+			continue
+		}
+
+		if protocol == "" {
+			protocol = "tcp"
+		}
+
+		s.Ports = append(s.Ports, port{Number: n, Protocol: strings.ToLower(protocol)})
+	}
+	sort.Slice(s.Ports, func(i, j int) bool {
+		if s.Ports[i].Number != s.Ports[j].Number {
+			return s.Ports[i].Number < s.Ports[j].Number
+		}
+		return s.Ports[i].Protocol < s.Ports[j].Protocol
+	})
+
+	for v := range cfg.Config.Volumes {
+		s.Volumes = append(s.Volumes, v)
+	}
+	sort.Strings(s.Volumes)
+
+	if h := s.Healthcheck; h != nil && (len(h.Test) == 0 || h.Test[0] == "NONE" || len(healthcheckCommand(h)) == 0) {
+		s.Healthcheck = nil
+	}
+
+	return s
+}
+
+// healthcheckCommand returns the healthcheck test as a command in exec form, empty when
+// the test has no command
+func healthcheckCommand(h *blob.Healthcheck) []string {
+	switch h.Test[0] {
+	case "CMD":
+		return h.Test[1:]
+	case "CMD-SHELL":
+		if len(h.Test) == 1 {
+			return nil
+		}
+		return []string{"/bin/sh", "-c", strings.Join(h.Test[1:], " ")}
+	}
+
+	// the test might be a command without the CMD prefix
+	return h.Test
+}
+
+type envVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+func splitEnv(env []string) []envVar {
+	vars := make([]envVar, 0, len(env))
+	for _, e := range env {
+		name, value, _ := strings.Cut(e, "=")
+		vars = append(vars, envVar{Name: name, Value: value})
+	}
+
+	return vars
+}
+
+type k8sContainer struct {
+	Name            string              `yaml:"name"`
+	Image           string              `yaml:"image"`
+	Command         []string            `yaml:"command,omitempty"`
+	Args            []string            `yaml:"args,omitempty"`
+	WorkingDir      string              `yaml:"workingDir,omitempty"`
+	Env             []envVar            `yaml:"env,omitempty"`
+	Ports           []k8sPort           `yaml:"ports,omitempty"`
+	SecurityContext *k8sSecurityContext `yaml:"securityContext,omitempty"`
+	VolumeMounts    []k8sVolumeMount    `yaml:"volumeMounts,omitempty"`
+	LivenessProbe   *k8sProbe           `yaml:"livenessProbe,omitempty"`
+}
+
+type k8sPort struct {
+	ContainerPort int    `yaml:"containerPort"`
+	Protocol      string `yaml:"protocol"`
+}
+
+type k8sSecurityContext struct {
+	RunAsUser  *int64 `yaml:"runAsUser,omitempty"`
+	RunAsGroup *int64 `yaml:"runAsGroup,omitempty"`
+}
+
+type k8sVolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+}
+
+type k8sProbe struct {
+	Exec struct {
+		Command []string `yaml:"command"`
+	} `yaml:"exec"`
+	InitialDelaySeconds int `yaml:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int `yaml:"periodSeconds,omitempty"`
+	TimeoutSeconds      int `yaml:"timeoutSeconds,omitempty"`
+	FailureThreshold    int `yaml:"failureThreshold,omitempty"`
+}
+
+type k8sPod struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Containers []k8sContainer `yaml:"containers"`
+		Volumes    []k8sVolume    `yaml:"volumes,omitempty"`
+	} `yaml:"spec"`
+}
+
+type k8sVolume struct {
+	Name     string   `yaml:"name"`
+	EmptyDir struct{} `yaml:"emptyDir"`
+}
+
+func seconds(d time.Duration) int {
+	return int(d.Round(time.Second) / time.Second)
+}
+
+// kubernetesContainer renders the spec as a kubernetes container. Kubernetes only
+// accepts numeric users, named users are reported as warnings.
+func kubernetesContainer(s *spec) k8sContainer {
+	c := k8sContainer{
+		Name:       s.Name,
+		Image:      s.Image,
+		Command:    s.Entrypoint,
+		Args:       s.Cmd,
+		WorkingDir: s.WorkingDir,
+		Env:        splitEnv(s.Env),
+	}
+
+	for _, p := range s.Ports {
+		c.Ports = append(c.Ports, k8sPort{ContainerPort: p.Number, Protocol: strings.ToUpper(p.Protocol)})
+	}
+
+	if s.User != "" {
+		user, group, _ := strings.Cut(s.User, ":")
+		sc := &k8sSecurityContext{}
+		if uid, err := strconv.ParseInt(user, 10, 64); err == nil {
+			sc.RunAsUser = &uid
+		} else {
+			s.Warnings = append(s.Warnings, fmt.Sprintf("user %q is not numeric and cannot be set in the security context", user))
+		}
+
+		if gid, err := strconv.ParseInt(group, 10, 64); err == nil {
+			sc.RunAsGroup = &gid
+		} else if group != "" {
+			s.Warnings = append(s.Warnings, fmt.Sprintf("group %q is not numeric and cannot be set in the security context", group))
+		}
+
+		if sc.RunAsUser != nil || sc.RunAsGroup != nil {
+			c.SecurityContext = sc
+		}
+	}
+
+	for i, name := range volumeNames(s.Volumes) {
+		c.VolumeMounts = append(c.VolumeMounts, k8sVolumeMount{Name: name, MountPath: s.Volumes[i]})
+	}
+
+	if h := s.Healthcheck; h != nil {
+		p := &k8sProbe{
+			InitialDelaySeconds: seconds(h.StartPeriod),
+			PeriodSeconds:       seconds(h.Interval),
+			TimeoutSeconds:      seconds(h.Timeout),
+			FailureThreshold:    h.Retries,
+		}
+		p.Exec.Command = healthcheckCommand(h)
+		c.LivenessProbe = p
+	}
+
+	return c
+}
+
+// kubernetesPod renders the spec as a pod with an emptyDir volume for every volume
+func kubernetesPod(s *spec) k8sPod {
+	p := k8sPod{APIVersion: "v1", Kind: "Pod"}
+	p.Metadata.Name = s.Name
+	p.Spec.Containers = []k8sContainer{kubernetesContainer(s)}
+
+	for _, name := range volumeNames(s.Volumes) {
+		p.Spec.Volumes = append(p.Spec.Volumes, k8sVolume{Name: name})
+	}
+
+	return p
+}
+
+type composeFile struct {
+	Services map[string]composeService `yaml:"services"`
+}
+
+type composeService struct {
+	Image       string              `yaml:"image"`
+	Entrypoint  []string            `yaml:"entrypoint,omitempty"`
+	Command     []string            `yaml:"command,omitempty"`
+	WorkingDir  string              `yaml:"working_dir,omitempty"`
+	User        string              `yaml:"user,omitempty"`
+	Environment []string            `yaml:"environment,omitempty"`
+	Ports       []string            `yaml:"ports,omitempty"`
+	Volumes     []string            `yaml:"volumes,omitempty"`
+	Healthcheck *composeHealthcheck `yaml:"healthcheck,omitempty"`
+}
+
+type composeHealthcheck struct {
+	Test          []string `yaml:"test"`
+	Interval      string   `yaml:"interval,omitempty"`
+	Timeout       string   `yaml:"timeout,omitempty"`
+	StartPeriod   string   `yaml:"start_period,omitempty"`
+	StartInterval string   `yaml:"start_interval,omitempty"`
+	Retries       int      `yaml:"retries,omitempty"`
+}
+
+func composeDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+
+	return d.String()
+}
+
+// portMapping publishes the port on the same host port. The protocol is always set so
+// YAML 1.1 parsers do not read the mapping as a base 60 number.
+func portMapping(p port) string {
+	return fmt.Sprintf("%d:%d/%s", p.Number, p.Number, p.Protocol)
+}
+
+// composeEscape escapes the dollar signs so docker compose does not interpolate them
+// with the variables of the host
+func composeEscape(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
+
+func composeEscapeAll(ss []string) []string {
+	if ss == nil {
+		return nil
+	}
+
+	escaped := make([]string, len(ss))
+	for i, s := range ss {
+		escaped[i] = composeEscape(s)
+	}
+
+	return escaped
+}
+
+// compose renders the spec as a docker compose service, volumes are anonymous
+func compose(s *spec) composeFile {
+	svc := composeService{
+		Image:       composeEscape(s.Image),
+		Entrypoint:  composeEscapeAll(s.Entrypoint),
+		Command:     composeEscapeAll(s.Cmd),
+		WorkingDir:  composeEscape(s.WorkingDir),
+		User:        composeEscape(s.User),
+		Environment: composeEscapeAll(s.Env),
+		Volumes:     composeEscapeAll(s.Volumes),
+	}
+
+	for _, p := range s.Ports {
+		svc.Ports = append(svc.Ports, portMapping(p))
+	}
+
+	if h := s.Healthcheck; h != nil {
+		svc.Healthcheck = &composeHealthcheck{
+			Test:          composeEscapeAll(h.Test),
+			Interval:      composeDuration(h.Interval),
+			Timeout:       composeDuration(h.Timeout),
+			StartPeriod:   composeDuration(h.StartPeriod),
+			StartInterval: composeDuration(h.StartInterval),
+			Retries:       h.Retries,
+		}
+	}
+
+	return composeFile{Services: map[string]composeService{s.Name: svc}}
+}
+
+var safeShellWord = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+func shellQuote(s string) string {
+	if safeShellWord.MatchString(s) {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dockerRun renders the spec as a docker run command line. As docker only accepts a
+// single executable as entrypoint the rest of it is passed along with the arguments.
+func dockerRun(s *spec) string {
+	args := []string{"docker run --rm --name " + shellQuote(s.Name)}
+	flag := func(name, value string) {
+		args = append(args, name+" "+shellQuote(value))
+	}
+
+	for _, e := range s.Env {
+		flag("-e", e)
+	}
+
+	for _, p := range s.Ports {
+		flag("-p", portMapping(p))
+	}
+
+	for _, v := range s.Volumes {
+		flag("-v", v)
+	}
+
+	if s.User != "" {
+		flag("-u", s.User)
+	}
+
+	if s.WorkingDir != "" {
+		flag("-w", s.WorkingDir)
+	}
+
+	if h := s.Healthcheck; h != nil {
+		cmd := strings.Join(h.Test[1:], " ")
+		if h.Test[0] != "CMD-SHELL" {
+			command := healthcheckCommand(h)
+			quoted := make([]string, 0, len(command))
+			for _, a := range command {
+				quoted = append(quoted, shellQuote(a))
+			}
+			cmd = strings.Join(quoted, " ")
+		}
+
+		flag("--health-cmd", cmd)
+		if h.Interval != 0 {
+			flag("--health-interval", h.Interval.String())
+		}
+		if h.Timeout != 0 {
+			flag("--health-timeout", h.Timeout.String())
+		}
+		if h.StartPeriod != 0 {
+			flag("--health-start-period", h.StartPeriod.String())
+		}
+		if h.Retries != 0 {
+			flag("--health-retries", strconv.Itoa(h.Retries))
+		}
+	}
+
+	var rest []string
+	if len(s.Entrypoint) > 0 {
+		flag("--entrypoint", s.Entrypoint[0])
+		rest = append(rest, s.Entrypoint[1:]...)
+	}
+	rest = append(rest, s.Cmd...)
+
+	last := shellQuote(s.Image)
+	for _, a := range rest {
+		last += " " + shellQuote(a)
+	}
+	args = append(args, last)
+
+	return strings.Join(args, " \\\n  ")
+}
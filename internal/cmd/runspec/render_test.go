@@ -0,0 +1,125 @@
+package runspec
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/stretchr/testify/require"
+)
+
+const ref = "registry.example.com/team/web:v1@sha256:aaa"
+
+func testConfig() *blob.ConfigBlob {
+	return &blob.ConfigBlob{
+		Config: blob.Config{
+			User:         "1000:2000",
+			ExposedPorts: map[string]struct{}{"8080/tcp": {}, "53/udp": {}, "invalid/tcp": {}},
+			Env:          []string{"GREETING=hello world"},
+			Entrypoint:   []string{"/app", "serve"},
+			Cmd:          []string{"--port", "8080"},
+			Volumes:      map[string]struct{}{"/var/lib/data": {}},
+			WorkingDir:   "/srv",
+			Healthcheck: &blob.Healthcheck{
+				Test:     []string{"CMD-SHELL", "curl -f localhost:8080"},
+				Interval: 30 * time.Second,
+				Retries:  3,
+			},
+		},
+	}
+}
+
+func TestNewSpec(t *testing.T) {
+	s := newSpec("team/Web_App", ref, testConfig())
+	require.Equal(t, "web-app", s.Name)
+	require.Equal(t, []port{{Number: 53, Protocol: "udp"}, {Number: 8080, Protocol: "tcp"}}, s.Ports)
+	require.Len(t, s.Warnings, 1)
+
+	for _, test := range [][]string{{"NONE"}, {"CMD"}, {"CMD-SHELL"}, {}} {
+		s = newSpec("app", ref, &blob.ConfigBlob{Config: blob.Config{Healthcheck: &blob.Healthcheck{Test: test}}})
+		require.Nil(t, s.Healthcheck, test)
+	}
+}
+
+func TestVolumeNames(t *testing.T) {
+	long := "/" + strings.Repeat("a", 70)
+	require.Equal(t, []string{
+		"var-lib",
+		"var-lib-2",
+		strings.Repeat("a", 63),
+		strings.Repeat("a", 61) + "-2",
+		"var-lib-3",
+	}, volumeNames([]string{"/var/lib", "/var-lib", long, long + "b", "/var_lib"}))
+}
+
+func TestKubernetesContainer(t *testing.T) {
+	s := newSpec("team/web", ref, testConfig())
+	c := kubernetesContainer(&s)
+
+	require.Equal(t, []string{"/app", "serve"}, c.Command)
+	require.Equal(t, []string{"--port", "8080"}, c.Args)
+	require.Equal(t, []envVar{{Name: "GREETING", Value: "hello world"}}, c.Env)
+	require.Equal(t, []k8sPort{{ContainerPort: 53, Protocol: "UDP"}, {ContainerPort: 8080, Protocol: "TCP"}}, c.Ports)
+	require.Equal(t, int64(1000), *c.SecurityContext.RunAsUser)
+	require.Equal(t, int64(2000), *c.SecurityContext.RunAsGroup)
+	require.Equal(t, []k8sVolumeMount{{Name: "var-lib-data", MountPath: "/var/lib/data"}}, c.VolumeMounts)
+	require.Equal(t, []string{"/bin/sh", "-c", "curl -f localhost:8080"}, c.LivenessProbe.Exec.Command)
+	require.Equal(t, 30, c.LivenessProbe.PeriodSeconds)
+
+	s.User = "nginx"
+	s.Warnings = nil
+	c = kubernetesContainer(&s)
+	require.Nil(t, c.SecurityContext)
+	require.Len(t, s.Warnings, 1)
+}
+
+func TestKubernetesPod(t *testing.T) {
+	s := newSpec("team/web", ref, testConfig())
+	p := kubernetesPod(&s)
+
+	require.Equal(t, "web", p.Metadata.Name)
+	require.Len(t, p.Spec.Containers, 1)
+	require.Equal(t, []k8sVolume{{Name: "var-lib-data"}}, p.Spec.Volumes)
+}
+
+func TestCompose(t *testing.T) {
+	s := newSpec("team/web", ref, testConfig())
+	svc := compose(&s).Services["web"]
+
+	require.Equal(t, ref, svc.Image)
+	require.Equal(t, []string{"53:53/udp", "8080:8080/tcp"}, svc.Ports)
+	require.Equal(t, "1000:2000", svc.User)
+	require.Equal(t, "30s", svc.Healthcheck.Interval)
+	require.Empty(t, svc.Healthcheck.Timeout)
+}
+
+func TestComposeEscapesDollarSigns(t *testing.T) {
+	cfg := testConfig()
+	cfg.Config.Env = []string{"PRICE=$5", "HOME_DIR=${HOME}"}
+	cfg.Config.Cmd = []string{"sh", "-c", "echo $PRICE"}
+
+	s := newSpec("team/web", ref, cfg)
+	svc := compose(&s).Services["web"]
+
+	require.Equal(t, []string{"PRICE=$$5", "HOME_DIR=$${HOME}"}, svc.Environment)
+	require.Equal(t, []string{"sh", "-c", "echo $$PRICE"}, svc.Command)
+	// the spec is shared with the other formats
+	require.Equal(t, []string{"sh", "-c", "echo $PRICE"}, s.Cmd)
+}
+
+func TestDockerRun(t *testing.T) {
+	s := newSpec("team/web", ref, testConfig())
+	require.Equal(t, `docker run --rm --name web \
+  -e 'GREETING=hello world' \
+  -p 53:53/udp \
+  -p 8080:8080/tcp \
+  -v /var/lib/data \
+  -u 1000:2000 \
+  -w /srv \
+  --health-cmd 'curl -f localhost:8080' \
+  --health-interval 30s \
+  --health-retries 3 \
+  --entrypoint /app \
+  registry.example.com/team/web:v1@sha256:aaa serve --port 8080`, dockerRun(&s))
+}
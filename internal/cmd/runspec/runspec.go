@@ -0,0 +1,126 @@
+package runspec
+
+import (
+	"fmt"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+	"gopkg.in/yaml.v3"
+)
+
+var Formats = map[Format][]string{
+	Container: {"container"},
+	Pod:       {"pod"},
+	Compose:   {"compose"},
+	Docker:    {"docker"},
+}
+
+type Format int
+
+const (
+	Container Format = iota
+	Pod
+	Compose
+	Docker
+)
+
+var format Format = Container
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&format, "string", Formats, enumflag.EnumCaseInsensitive),
+		"format",
+		"Sets what to render: a kubernetes container, a kubernetes pod, a compose service or a docker run command",
+	)
+	RootCmd.Flags().String("platform", "", "Uses the image for the given platform (e.g. linux/amd64) and pins its manifest digest")
+}
+
+var RootCmd = &cobra.Command{
+	Use:   "runspec <image>",
+	Short: "Generates a kubernetes container, compose service or docker run command from the image config",
+	Long: "Generates a kubernetes container, compose service or docker run command from the image config, " +
+		"with the image pinned to its digest. Multi-platform images are pinned to the index digest unless a platform is given.",
+	Example: "$ nuro runspec nginx:1.27 --format pod",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		m, err := manifest.Resolve(ctx, registry, insecure, name, reference, platform)
+		if err != nil {
+			return fmt.Errorf("resolving manifest: %w", err)
+		}
+
+		manifest.WarnSchema1(cmd, m)
+
+		cfg, err := blob.GetConfig(ctx, registry, insecure, name, m)
+		if err != nil {
+			return fmt.Errorf("getting config blob: %w", err)
+		}
+
+		pinned := m.Digest
+		if m.IndexDigest != "" && platform == nil {
+			pinned = m.IndexDigest
+		}
+
+		s := newSpec(name, image.FormatReference(registry, name, tag, pinned), cfg)
+
+		var out any
+		switch format {
+		case Pod:
+			out = kubernetesPod(&s)
+		case Compose:
+			out = compose(&s)
+		case Docker:
+			out = dockerRun(&s)
+		default:
+			out = kubernetesContainer(&s)
+		}
+
+		for _, w := range s.Warnings {
+			cmd.PrintErrln("Warning: " + w)
+		}
+
+		if line, ok := out.(string); ok {
+			if _, err := fmt.Fprintln(cmd.OutOrStdout(), line); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+
+			return nil
+		}
+
+		enc := yaml.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent(2)
+		if err := enc.Encode(out); err != nil {
+			return fmt.Errorf("writing to stdout: %w", err)
+		}
+
+		return nil
+	},
+}
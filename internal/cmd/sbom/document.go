@@ -0,0 +1,147 @@
+package sbom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	spdxPredicateType      = "https://spdx.dev/Document"
+	cycloneDXPredicateType = "https://cyclonedx.org/bom"
+)
+
+// sbomArtifactTypes are the artifact types of SBOMs attached as referrers
+var sbomArtifactTypes = []string{
+	"application/spdx+json",
+	"application/vnd.cyclonedx+json",
+	"application/vnd.dev.cosign.artifact.sbom.v1+json",
+}
+
+func isSBOM(predicateType string) bool {
+	return strings.HasPrefix(predicateType, spdxPredicateType) || strings.HasPrefix(predicateType, cycloneDXPredicateType)
+}
+
+// Package is a package listed in a SBOM
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	License string `json:"license,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+// parsePackages lists the packages in a SPDX or CycloneDX JSON document
+func parsePackages(document []byte) (format string, packages []Package, err error) {
+	var probe struct {
+		SPDXVersion string `json:"spdxVersion"`
+		BOMFormat   string `json:"bomFormat"`
+	}
+
+	if err := json.Unmarshal(document, &probe); err != nil {
+		return "", nil, fmt.Errorf("decoding SBOM: %w", err)
+	}
+
+	switch {
+	case probe.SPDXVersion != "":
+		packages, err = parseSPDX(document)
+		return probe.SPDXVersion, packages, err
+	case probe.BOMFormat == "CycloneDX":
+		packages, err = parseCycloneDX(document)
+		return probe.BOMFormat, packages, err
+	}
+
+	return "", nil, errors.New("unsupported SBOM format, expected SPDX or CycloneDX JSON")
+}
+
+func parseSPDX(document []byte) ([]Package, error) {
+	var doc struct {
+		Packages []struct {
+			Name             string `json:"name"`
+			VersionInfo      string `json:"versionInfo"`
+			LicenseConcluded string `json:"licenseConcluded"`
+			LicenseDeclared  string `json:"licenseDeclared"`
+			ExternalRefs     []struct {
+				ReferenceType    string `json:"referenceType"`
+				ReferenceLocator string `json:"referenceLocator"`
+			} `json:"externalRefs"`
+		} `json:"packages"`
+	}
+
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return nil, fmt.Errorf("decoding SPDX document: %w", err)
+	}
+
+	packages := make([]Package, 0, len(doc.Packages))
+	for _, p := range doc.Packages {
+		pkg := Package{Name: p.Name, Version: p.VersionInfo, License: spdxLicense(p.LicenseConcluded)}
+		if pkg.License == "" {
+			pkg.License = spdxLicense(p.LicenseDeclared)
+		}
+
+		for _, ref := range p.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				pkg.PURL = ref.ReferenceLocator
+				break
+			}
+		}
+
+		packages = append(packages, pkg)
+	}
+
+	return packages, nil
+}
+
+// spdxLicense ignores the SPDX placeholders for missing licenses
+func spdxLicense(license string) string {
+	if license == "NOASSERTION" || license == "NONE" {
+		return ""
+	}
+
+	return license
+}
+
+func parseCycloneDX(document []byte) ([]Package, error) {
+	var doc struct {
+		Components []struct {
+			Name     string `json:"name"`
+			Version  string `json:"version"`
+			PURL     string `json:"purl"`
+			Licenses []struct {
+				Expression string `json:"expression"`
+				License    struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"license"`
+			} `json:"licenses"`
+		} `json:"components"`
+	}
+
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return nil, fmt.Errorf("decoding CycloneDX document: %w", err)
+	}
+
+	packages := make([]Package, 0, len(doc.Components))
+	for _, c := range doc.Components {
+		licenses := make([]string, 0, len(c.Licenses))
+		for _, l := range c.Licenses {
+			switch {
+			case l.Expression != "":
+				licenses = append(licenses, l.Expression)
+			case l.License.ID != "":
+				licenses = append(licenses, l.License.ID)
+			case l.License.Name != "":
+				licenses = append(licenses, l.License.Name)
+			}
+		}
+
+		packages = append(packages, Package{
+			Name:    c.Name,
+			Version: c.Version,
+			License: strings.Join(licenses, " AND "),
+			PURL:    c.PURL,
+		})
+	}
+
+	return packages, nil
+}
@@ -0,0 +1,77 @@
+package sbom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePackages(t *testing.T) {
+	tests := []struct {
+		name             string
+		document         string
+		expectedFormat   string
+		expectedPackages []Package
+		expectErr        bool
+	}{
+		{
+			name: "SPDX document",
+			document: `{
+				"spdxVersion": "SPDX-2.3",
+				"packages": [
+					{
+						"name": "musl",
+						"versionInfo": "1.2.5-r0",
+						"licenseConcluded": "MIT",
+						"externalRefs": [{"referenceType": "purl", "referenceLocator": "pkg:apk/alpine/musl@1.2.5-r0"}]
+					},
+					{"name": "busybox", "versionInfo": "1.36.1-r29", "licenseConcluded": "NOASSERTION", "licenseDeclared": "GPL-2.0-only"}
+				]
+			}`,
+			expectedFormat: "SPDX-2.3",
+			expectedPackages: []Package{
+				{Name: "musl", Version: "1.2.5-r0", License: "MIT", PURL: "pkg:apk/alpine/musl@1.2.5-r0"},
+				{Name: "busybox", Version: "1.36.1-r29", License: "GPL-2.0-only"},
+			},
+		},
+		{
+			name: "CycloneDX document",
+			document: `{
+				"bomFormat": "CycloneDX",
+				"specVersion": "1.5",
+				"components": [
+					{"name": "musl", "version": "1.2.5-r0", "purl": "pkg:apk/alpine/musl@1.2.5-r0", "licenses": [{"license": {"id": "MIT"}}]},
+					{"name": "openssl", "version": "3.3.1", "licenses": [{"expression": "Apache-2.0"}]}
+				]
+			}`,
+			expectedFormat: "CycloneDX",
+			expectedPackages: []Package{
+				{Name: "musl", Version: "1.2.5-r0", License: "MIT", PURL: "pkg:apk/alpine/musl@1.2.5-r0"},
+				{Name: "openssl", Version: "3.3.1", License: "Apache-2.0"},
+			},
+		},
+		{
+			name:      "unknown document",
+			document:  `{"name": "sbom"}`,
+			expectErr: true,
+		},
+		{
+			name:      "invalid document",
+			document:  `<sbom/>`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, packages, err := parsePackages([]byte(tt.document))
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedFormat, format)
+				require.Equal(t, tt.expectedPackages, packages)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package sbom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jcchavezs/nuro/internal/api/attestation"
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/api/referrers"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+)
+
+var Formats = map[OutputFormat][]string{
+	Table: {"table"},
+	JSON:  {"json"},
+}
+
+type OutputFormat int
+
+const (
+	Table OutputFormat = iota
+	JSON
+)
+
+var outputFormat OutputFormat = Table
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&outputFormat, "string", Formats, enumflag.EnumCaseInsensitive),
+		"output",
+		"Sets the output format of the package summary",
+	)
+	RootCmd.Flags().String("platform", "", "Platform of the image (e.g. linux/amd64), defaults to the first manifest")
+	RootCmd.Flags().Bool("all-platforms", false, "Gets the SBOM for every platform in the index")
+	RootCmd.Flags().String("file", "", "Writes the raw SBOM document to the given file instead of printing the package summary, suffixed with the platform when using --all-platforms")
+
+	RootCmd.MarkFlagsMutuallyExclusive("platform", "all-platforms")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "sbom <image>",
+	Short:   "Shows the SBOM attached to a given image",
+	Example: "$ nuro sbom docker/dockerfile:1 --platform linux/amd64 --file sbom.spdx.json",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		var platform *manifest.Platform
+		if p, _ := cmd.Flags().GetString("platform"); p != "" {
+			if platform, err = manifest.ParsePlatform(p); err != nil {
+				return fmt.Errorf("parsing platform: %w", err)
+			}
+		}
+
+		allPlatforms, _ := cmd.Flags().GetBool("all-platforms")
+		file, _ := cmd.Flags().GetString("file")
+
+		res, err := manifest.Get(ctx, registry, insecure, name, reference)
+		if err != nil {
+			return fmt.Errorf("getting manifest: %w", err)
+		}
+
+		var (
+			idx     *manifest.Index
+			targets []manifest.Descriptor
+		)
+
+		if res.IsIndex() {
+			if idx, err = res.Index(); err != nil {
+				return fmt.Errorf("getting index: %w", err)
+			}
+
+			if allPlatforms {
+				targets = idx.Images()
+			} else {
+				d, err := manifest.SelectManifest(idx, platform)
+				if err != nil {
+					return err
+				}
+
+				targets = []manifest.Descriptor{d}
+			}
+		} else {
+			targets = []manifest.Descriptor{{MediaType: res.MediaType, Digest: res.Digest}}
+		}
+
+		var sboms []sbom
+		for _, t := range targets {
+			s := sbom{Digest: t.Digest}
+			if t.Platform != nil {
+				s.Platform = t.Platform.String()
+			}
+
+			document, err := findDocument(ctx, registry, insecure, name, idx, t.Digest)
+			if err != nil {
+				return fmt.Errorf("getting SBOM for %s: %w", t.Digest, err)
+			}
+
+			if document == nil {
+				if len(targets) == 1 {
+					return errors.New("no SBOM found")
+				}
+
+				cmd.PrintErrf("Warning: no SBOM found for platform %s\n", s.Platform)
+				continue
+			}
+
+			if file != "" {
+				f := file
+				if allPlatforms {
+					f = platformFileName(file, s.Platform)
+				}
+
+				if err := os.WriteFile(f, document, 0o644); err != nil {
+					return fmt.Errorf("writing SBOM: %w", err)
+				}
+
+				continue
+			}
+
+			if s.Format, s.Packages, err = parsePackages(document); err != nil {
+				return err
+			}
+
+			sboms = append(sboms, s)
+		}
+
+		if file != "" {
+			return nil
+		}
+
+		switch outputFormat {
+		case JSON:
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(sboms); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		default:
+			printPackages(cmd.OutOrStdout(), sboms, allPlatforms)
+		}
+
+		return nil
+	},
+}
+
+type sbom struct {
+	Platform string    `json:"platform,omitempty"`
+	Digest   string    `json:"digest"`
+	Format   string    `json:"format"`
+	Packages []Package `json:"packages"`
+}
+
+// findDocument finds the SBOM document attached to the image manifest with the given
+// digest either as an in-toto attestation or as a referrer, preferring attestations. The
+// referrers are listed and fetched only once for both. It returns nil when the image has
+// no SBOM.
+func findDocument(ctx context.Context, registry string, insecure bool, name string, idx *manifest.Index, digest string) ([]byte, error) {
+	statements, err := attestation.FromIndex(ctx, registry, insecure, name, idx, digest, isSBOM)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(statements) > 0 {
+		return statements[0].Predicate, nil
+	}
+
+	descriptors, err := referrers.List(ctx, registry, insecure, name, digest, "")
+	if err != nil {
+		return nil, fmt.Errorf("listing referrers: %w", err)
+	}
+
+	// the document of an SBOM artifact is only downloaded when there is no attestation
+	var document *manifest.Descriptor
+	for _, d := range descriptors {
+		res, err := manifest.GetByDescriptor(ctx, registry, insecure, name, d)
+		if err != nil {
+			return nil, fmt.Errorf("getting referrer manifest: %w", err)
+		}
+
+		if !res.IsManifest() {
+			continue
+		}
+
+		m, err := res.Manifest()
+		if err != nil {
+			return nil, fmt.Errorf("getting referrer manifest: %w", err)
+		}
+
+		if slices.Contains(sbomArtifactTypes, d.ArtifactType) {
+			if document == nil && len(m.Layers) > 0 {
+				document = &m.Layers[0]
+			}
+			continue
+		}
+
+		statements, err := attestation.FromManifest(ctx, registry, insecure, name, m, isSBOM)
+		if err != nil {
+			return nil, fmt.Errorf("getting statements from referrer: %w", err)
+		}
+
+		if len(statements) > 0 {
+			return statements[0].Predicate, nil
+		}
+	}
+
+	if document == nil {
+		return nil, nil
+	}
+
+	r, err := blob.Get(ctx, registry, insecure, name, document.Digest, document.Size)
+	if err != nil {
+		return nil, fmt.Errorf("getting SBOM document: %w", err)
+	}
+	defer r.Close() //nolint
+
+	return io.ReadAll(r)
+}
+
+// platformFileName adds the platform to the file name before the extension, e.g.
+// sbom.json becomes sbom.linux-amd64.json
+func platformFileName(file, platform string) string {
+	if platform == "" {
+		return file
+	}
+
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + strings.ReplaceAll(platform, "/", "-") + ext
+}
+
+func printPackages(w io.Writer, sboms []sbom, withPlatform bool) {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+
+	header := table.Row{"Name", "Version", "License", "PURL"}
+	if withPlatform {
+		header = append(table.Row{"Platform"}, header...)
+	}
+	t.AppendHeader(header)
+
+	for _, s := range sboms {
+		for _, p := range s.Packages {
+			row := table.Row{p.Name, p.Version, p.License, p.PURL}
+			if withPlatform {
+				row = append(table.Row{s.Platform}, row...)
+			}
+			t.AppendRow(row)
+		}
+	}
+
+	t.Render()
+}
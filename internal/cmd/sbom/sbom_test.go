@@ -0,0 +1,59 @@
+package sbom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlatformFileName(t *testing.T) {
+	require.Equal(t, "sbom.linux-amd64.json", platformFileName("sbom.json", "linux/amd64"))
+	require.Equal(t, "out/sbom.spdx.linux-arm64-v8.json", platformFileName("out/sbom.spdx.json", "linux/arm64/v8"))
+	require.Equal(t, "sbom.linux-amd64", platformFileName("sbom", "linux/amd64"))
+	require.Equal(t, "sbom.json", platformFileName("sbom.json", ""))
+}
+
+func TestFindDocument(t *testing.T) {
+	reg := registrytest.New(t)
+	reg.Referrers = true
+
+	image := reg.PutManifest(t, manifest.Manifest{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIManifestV1ContentType,
+		Config:        reg.PutBlob([]byte(`{"architecture":"amd64","os":"linux"}`), manifest.OCIConfigV1ContentType),
+	}, "latest")
+
+	empty := reg.PutBlob([]byte("{}"), manifest.OCIEmptyContentType)
+
+	statement := reg.PutBlob([]byte(`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://slsa.dev/provenance/v0.2","predicate":{}}`), "application/vnd.in-toto+json")
+	provenance := reg.PutManifest(t, manifest.Manifest{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIManifestV1ContentType,
+		ArtifactType:  "application/vnd.in-toto+json",
+		Config:        empty,
+		Layers:        []manifest.Descriptor{statement},
+		Subject:       &image,
+	})
+
+	document := []byte(`{"spdxVersion":"SPDX-2.3","packages":[]}`)
+	sbom := reg.PutManifest(t, manifest.Manifest{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIManifestV1ContentType,
+		ArtifactType:  "application/spdx+json",
+		Config:        empty,
+		Layers:        []manifest.Descriptor{reg.PutBlob(document, "application/spdx+json")},
+		Subject:       &image,
+	})
+
+	b, err := findDocument(context.Background(), reg.Host(), true, "app", nil, image.Digest)
+	require.NoError(t, err)
+	require.Equal(t, document, b)
+
+	// every referrer is listed and fetched once
+	require.Equal(t, 1, reg.Requests("GET", "/v2/app/referrers/"+image.Digest))
+	require.Equal(t, 1, reg.Requests("GET", "/v2/app/manifests/"+provenance.Digest))
+	require.Equal(t, 1, reg.Requests("GET", "/v2/app/manifests/"+sbom.Digest))
+}
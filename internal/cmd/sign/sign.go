@@ -0,0 +1,100 @@
+package sign
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/cache"
+	"github.com/jcchavezs/nuro/internal/cosign"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().String("key", "", "Path to the unencrypted PEM encoded ECDSA or Ed25519 private key")
+	RootCmd.Flags().StringToString("annotation", nil, "Annotations added to the signed payload (e.g. --annotation env=prod)")
+	RootCmd.Flags().Bool("referrer", false, "Attaches the signature as a referrer instead of pushing it under the signature tag")
+
+	_ = RootCmd.MarkFlagRequired("key")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "sign <image>",
+	Short:   "Signs a given image with a local key and pushes a cosign compatible signature",
+	Example: "$ nuro sign ghcr.io/org/app:v1.0.0 --key cosign.key --annotation env=prod",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		reference := digest
+		if reference == "" {
+			reference = tag
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name, Push: true})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		keyFile, _ := cmd.Flags().GetString("key")
+		b, err := os.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("reading private key: %w", err)
+		}
+
+		key, err := cosign.LoadPrivateKey(b)
+		if err != nil {
+			return err
+		}
+
+		annotations, err := cmd.Flags().GetStringToString("annotation")
+		if err != nil {
+			return fmt.Errorf("getting annotation flag: %w", err)
+		}
+
+		referrer, _ := cmd.Flags().GetBool("referrer")
+
+		// a cached tag resolution could be stale and the signature cover another image
+		d, err := manifest.Head(cache.WithoutCache(ctx), registry, insecure, name, reference)
+		if err != nil {
+			return fmt.Errorf("getting manifest descriptor: %w", err)
+		}
+
+		payload, err := cosign.NewPayload(image.FormatReference(registry, name, "", ""), d.Digest, annotations)
+		if err != nil {
+			return fmt.Errorf("building payload: %w", err)
+		}
+
+		sig, err := cosign.Sign(key, payload)
+		if err != nil {
+			return fmt.Errorf("signing payload: %w", err)
+		}
+
+		pushed, err := cosign.Push(ctx, registry, insecure, name, d.Digest, payload, sig, cosign.PushOptions{
+			Referrer: referrer,
+			Subject:  d,
+		})
+		if err != nil {
+			return err
+		}
+
+		ref := image.FormatReference(registry, name, pushed, "")
+		if referrer {
+			ref = image.FormatReference(registry, name, "", pushed)
+		}
+
+		if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Signed %s, pushed signature to %s\n", d.Digest, ref); err != nil {
+			return fmt.Errorf("writing to stdout: %w", err)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,197 @@
+package sign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/api/referrers"
+	"github.com/jcchavezs/nuro/internal/cache"
+	"github.com/jcchavezs/nuro/internal/cosign"
+	"github.com/jcchavezs/nuro/internal/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+// writePrivateKey writes the SEC1 PEM encoded private key into a file
+func writePrivateKey(t *testing.T, key *ecdsa.PrivateKey) string {
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "cosign.key")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600))
+
+	return path
+}
+
+// verify returns the payloads of the signatures of the digest verified with the key
+func verify(t *testing.T, reg *registrytest.Registry, digest string, key *ecdsa.PrivateKey) []*cosign.Payload {
+	signatures, err := cosign.Find(context.Background(), reg.Host(), true, "org/app", digest)
+	require.NoError(t, err)
+
+	var payloads []*cosign.Payload
+	for _, s := range signatures {
+		if p, err := s.Verify(&key.PublicKey, digest); err == nil {
+			payloads = append(payloads, p)
+		}
+	}
+
+	return payloads
+}
+
+func TestSign(t *testing.T) {
+	reg := registrytest.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	t.Run("signature tag", func(t *testing.T) {
+		d := reg.PutImage(t, []byte(`{"architecture":"amd64","os":"linux"}`), nil, "tag")
+
+		out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:tag", "--insecure", "--key", writePrivateKey(t, key))
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("Signed %s, pushed signature to %s/org/app:%s\n", d.Digest, reg.Host(), cosign.SignatureTag(d.Digest)), out)
+
+		payloads := verify(t, reg, d.Digest, key)
+		require.Len(t, payloads, 1)
+		require.Equal(t, reg.Host()+"/org/app", payloads[0].Critical.Identity.DockerReference)
+
+		// a second key appends its signature to the ones under the tag
+		other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		_, err = registrytest.Execute(t, RootCmd, reg.Host()+"/org/app@"+d.Digest, "--insecure", "--key", writePrivateKey(t, other))
+		require.NoError(t, err)
+
+		b, ok := reg.Manifest(cosign.SignatureTag(d.Digest))
+		require.True(t, ok)
+		res := manifest.Response{MediaType: manifest.OCIManifestV1ContentType, Body: b}
+		m, err := res.Manifest()
+		require.NoError(t, err)
+		require.Len(t, m.Layers, 2)
+
+		require.Len(t, verify(t, reg, d.Digest, key), 1)
+		require.Len(t, verify(t, reg, d.Digest, other), 1)
+	})
+
+	for _, supported := range []bool{true, false} {
+		t.Run(fmt.Sprintf("referrer with referrers API %t", supported), func(t *testing.T) {
+			reg.Referrers = supported
+
+			config := fmt.Sprintf(`{"architecture":"amd64","os":"linux","referrers":%t}`, supported)
+			d := reg.PutImage(t, []byte(config), nil, "referrer")
+
+			out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:referrer", "--insecure", "--key", writePrivateKey(t, key), "--referrer")
+			require.NoError(t, err)
+
+			descriptors, err := referrers.List(context.Background(), reg.Host(), true, "org/app", d.Digest, cosign.ArtifactType)
+			require.NoError(t, err)
+			require.Len(t, descriptors, 1)
+			require.Equal(t, fmt.Sprintf("Signed %s, pushed signature to %s/org/app@%s\n", d.Digest, reg.Host(), descriptors[0].Digest), out)
+
+			_, ok := reg.Manifest(cosign.SignatureTag(d.Digest))
+			require.False(t, ok)
+
+			require.Len(t, verify(t, reg, d.Digest, key), 1)
+		})
+	}
+
+	t.Run("annotations", func(t *testing.T) {
+		d := reg.PutImage(t, []byte(`{"architecture":"arm64","os":"linux"}`), nil, "annotated")
+
+		_, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:annotated", "--insecure", "--key", writePrivateKey(t, key), "--annotation", "env=prod")
+		require.NoError(t, err)
+
+		payloads := verify(t, reg, d.Digest, key)
+		require.Len(t, payloads, 1)
+		require.Equal(t, map[string]any{"env": "prod"}, payloads[0].Optional)
+	})
+
+	t.Run("missing image", func(t *testing.T) {
+		_, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:missing", "--insecure", "--key", writePrivateKey(t, key))
+		require.ErrorContains(t, err, "getting manifest descriptor")
+	})
+}
+
+func TestSignWithStaleCache(t *testing.T) {
+	for _, referrer := range []bool{false, true} {
+		t.Run(fmt.Sprintf("referrer %t", referrer), func(t *testing.T) {
+			reg := registrytest.New(t)
+			d := reg.PutImage(t, []byte(`{"architecture":"amd64","os":"linux"}`), nil, "latest")
+
+			dir := t.TempDir()
+			cache.Init(dir, time.Hour, 0, false)
+			t.Cleanup(func() { cache.Init("", 0, 0, false) })
+
+			keys := make([]*ecdsa.PrivateKey, 3)
+			for i := range keys {
+				var err error
+				keys[i], err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				require.NoError(t, err)
+			}
+
+			sign := func(key *ecdsa.PrivateKey) {
+				args := []string{reg.Host() + "/org/app:latest", "--insecure", "--key", writePrivateKey(t, key)}
+				if referrer {
+					args = append(args, "--referrer")
+				}
+
+				_, err := registrytest.Execute(t, RootCmd, args...)
+				require.NoError(t, err)
+			}
+
+			sign(keys[0])
+			// caches the signatures tag and the referrers tag
+			require.Len(t, verify(t, reg, d.Digest, keys[0]), 1)
+
+			// another client signs the image meanwhile
+			cache.Init("", 0, 0, false)
+			sign(keys[1])
+			cache.Init(dir, time.Hour, 0, false)
+
+			sign(keys[2])
+
+			cache.Init("", 0, 0, false)
+			for _, key := range keys {
+				require.Len(t, verify(t, reg, d.Digest, key), 1)
+			}
+		})
+	}
+}
+
+func TestSignMovedTag(t *testing.T) {
+	reg := registrytest.New(t)
+	first := reg.PutImage(t, []byte(`{"architecture":"amd64","os":"linux"}`), nil, "latest")
+
+	cache.Init(t.TempDir(), time.Hour, 0, false)
+	t.Cleanup(func() { cache.Init("", 0, 0, false) })
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:latest", "--insecure", "--key", writePrivateKey(t, key))
+	require.NoError(t, err)
+	require.Contains(t, out, "Signed "+first.Digest)
+
+	// e.g. inspecting the image caches the manifest along with the tag resolution
+	_, err = manifest.Get(context.Background(), reg.Host(), true, "org/app", "latest")
+	require.NoError(t, err)
+
+	// the tag is moved by another client while its resolution is cached
+	second := reg.PutImage(t, []byte(`{"architecture":"arm64","os":"linux"}`), nil, "latest")
+
+	out, err = registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:latest", "--insecure", "--key", writePrivateKey(t, key))
+	require.NoError(t, err)
+	require.Contains(t, out, "Signed "+second.Digest)
+
+	cache.Init("", 0, 0, false)
+	require.Len(t, verify(t, reg, second.Digest, key), 1)
+}
@@ -0,0 +1,143 @@
+package verify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/cosign"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+	"github.com/thediveo/enumflag"
+)
+
+var Formats = map[OutputFormat][]string{
+	Table: {"table"},
+	JSON:  {"json"},
+}
+
+type OutputFormat int
+
+const (
+	Table OutputFormat = iota
+	JSON
+)
+
+var outputFormat OutputFormat = Table
+
+func init() {
+	RootCmd.PersistentFlags().Bool("insecure", false, "Allow communication with an insecure registry")
+	RootCmd.Flags().Var(
+		enumflag.New(&outputFormat, "string", Formats, enumflag.EnumCaseInsensitive),
+		"output",
+		"Sets the output format",
+	)
+	RootCmd.Flags().String("key", "", "Path to the PEM encoded ECDSA or Ed25519 public key")
+
+	_ = RootCmd.MarkFlagRequired("key")
+}
+
+var RootCmd = &cobra.Command{
+	Use:     "verify <image>",
+	Short:   "Verifies the cosign signatures of a given image offline using a public key",
+	Example: "$ nuro verify ghcr.io/org/app:v1.0.0 --key cosign.pub",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry, name, tag, digest, err := image.ParseImage(args[0])
+		if err != nil {
+			return fmt.Errorf("parsing image: %w", err)
+		}
+
+		ctx := auth.InjectImageMetadata(cmd.Context(), auth.ImageMetadata{Registry: registry, Name: name})
+
+		insecure, err := cmd.Flags().GetBool("insecure")
+		if err != nil {
+			return fmt.Errorf("getting insecure flag: %w", err)
+		}
+
+		keyFile, _ := cmd.Flags().GetString("key")
+		key, err := os.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("reading public key: %w", err)
+		}
+
+		pub, err := cosign.LoadPublicKey(key)
+		if err != nil {
+			return err
+		}
+
+		if digest == "" {
+			d, err := manifest.Head(ctx, registry, insecure, name, tag)
+			if err != nil {
+				return fmt.Errorf("getting manifest descriptor: %w", err)
+			}
+
+			digest = d.Digest
+		}
+
+		signatures, err := cosign.Find(ctx, registry, insecure, name, digest)
+		if err != nil {
+			return fmt.Errorf("finding signatures: %w", err)
+		}
+
+		if len(signatures) == 0 {
+			return fmt.Errorf("no signatures found for %s", digest)
+		}
+
+		results := make([]result, 0, len(signatures))
+		verified := 0
+		for _, s := range signatures {
+			r := result{Source: s.Source, Digest: s.Digest, Verified: true}
+
+			p, err := s.Verify(pub, digest)
+			if err != nil {
+				r.Verified = false
+				r.Error = err.Error()
+			} else {
+				r.DockerReference = p.Critical.Identity.DockerReference
+				r.Annotations = p.Optional
+				verified++
+			}
+
+			results = append(results, r)
+		}
+
+		switch outputFormat {
+		case JSON:
+			if err = json.NewEncoder(cmd.OutOrStdout()).Encode(results); err != nil {
+				return fmt.Errorf("writing to stdout: %w", err)
+			}
+		default:
+			t := table.NewWriter()
+			t.SetOutputMirror(cmd.OutOrStdout())
+			t.AppendHeader(table.Row{"Source", "Payload Digest", "Docker Reference", "Verified"})
+			for _, r := range results {
+				status := "yes"
+				if !r.Verified {
+					status = "no: " + r.Error
+				}
+				t.AppendRow(table.Row{r.Source, r.Digest, r.DockerReference, status})
+			}
+			t.Render()
+		}
+
+		if verified == 0 {
+			return errors.New("no valid signatures found")
+		}
+
+		return nil
+	},
+}
+
+type result struct {
+	Source          string         `json:"source"`
+	Digest          string         `json:"digest"`
+	DockerReference string         `json:"dockerReference,omitempty"`
+	Annotations     map[string]any `json:"annotations,omitempty"`
+	Verified        bool           `json:"verified"`
+	Error           string         `json:"error,omitempty"`
+}
@@ -0,0 +1,96 @@
+package verify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/cosign"
+	"github.com/jcchavezs/nuro/internal/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+// writePublicKey writes the PEM encoded public key of the private key into a file
+func writePublicKey(t *testing.T, key *ecdsa.PrivateKey) string {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "cosign.pub")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	reg := registrytest.New(t)
+
+	signed := reg.PutImage(t, []byte(`{"architecture":"amd64","os":"linux"}`), nil, "signed")
+	reg.PutImage(t, []byte(`{"architecture":"arm64","os":"linux"}`), nil, "unsigned")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	payload, err := cosign.NewPayload(reg.Host()+"/org/app", signed.Digest, map[string]string{"env": "prod"})
+	require.NoError(t, err)
+
+	sig, err := cosign.Sign(key, payload)
+	require.NoError(t, err)
+
+	_, err = cosign.Push(context.Background(), reg.Host(), true, "org/app", signed.Digest, payload, sig, cosign.PushOptions{Subject: signed})
+	require.NoError(t, err)
+
+	t.Run("valid signature", func(t *testing.T) {
+		out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:signed", "--insecure", "--key", writePublicKey(t, key), "--output", "json")
+		require.NoError(t, err)
+
+		var results []result
+		require.NoError(t, json.Unmarshal([]byte(out), &results))
+		require.Len(t, results, 1)
+		require.True(t, results[0].Verified)
+		require.Equal(t, reg.Host()+"/org/app", results[0].DockerReference)
+		require.Equal(t, map[string]any{"env": "prod"}, results[0].Annotations)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app@"+signed.Digest, "--insecure", "--key", writePublicKey(t, other), "--output", "json")
+		require.EqualError(t, err, "no valid signatures found")
+
+		var results []result
+		require.NoError(t, json.Unmarshal([]byte(out), &results))
+		require.Len(t, results, 1)
+		require.False(t, results[0].Verified)
+		require.Equal(t, cosign.ErrInvalidSignature.Error(), results[0].Error)
+	})
+
+	t.Run("signature for another image", func(t *testing.T) {
+		unsigned, err := manifest.Head(context.Background(), reg.Host(), true, "org/app", "unsigned")
+		require.NoError(t, err)
+
+		// the signature of the signed image is copied under the tag of the unsigned one
+		b, ok := reg.Manifest(cosign.SignatureTag(signed.Digest))
+		require.True(t, ok)
+		reg.PutRawManifest(b, manifest.OCIManifestV1ContentType, cosign.SignatureTag(unsigned.Digest))
+
+		out, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:unsigned", "--insecure", "--key", writePublicKey(t, key))
+		require.EqualError(t, err, "no valid signatures found")
+		require.Contains(t, out, "payload refers to "+signed.Digest)
+	})
+
+	t.Run("no signatures", func(t *testing.T) {
+		reg.PutImage(t, []byte(`{"architecture":"s390x","os":"linux"}`), nil, "other")
+
+		_, err := registrytest.Execute(t, RootCmd, reg.Host()+"/org/app:other", "--insecure", "--key", writePublicKey(t, key))
+		require.ErrorContains(t, err, "no signatures found")
+	})
+}
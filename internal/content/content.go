@@ -0,0 +1,144 @@
+package content
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// VerificationError is returned when some content does not match its expected
+// digest or size.
+type VerificationError struct {
+	// Field is the property that failed the verification, either "digest" or "size"
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("content verification failed: expected %s %s, got %s", e.Field, e.Expected, e.Actual)
+}
+
+var hexLengths = map[string]int{
+	"sha256": sha256.Size * 2,
+	"sha512": sha512.Size * 2,
+}
+
+// ParseDigest validates a digest in the form algorithm:hex and returns its parts
+func ParseDigest(digest string) (algorithm string, hex string, err error) {
+	algorithm, hex, ok := strings.Cut(digest, ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid digest %q", digest)
+	}
+
+	length, ok := hexLengths[algorithm]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+
+	if len(hex) != length || strings.Trim(hex, "0123456789abcdef") != "" {
+		return "", "", fmt.Errorf("invalid digest %q", digest)
+	}
+
+	return algorithm, hex, nil
+}
+
+// IsDigest returns true if the reference is a digest rather than a tag. Tags cannot
+// contain colons so any reference containing one is considered a digest.
+func IsDigest(reference string) bool {
+	return strings.Contains(reference, ":")
+}
+
+// FromBytes returns the sha256 digest of the given content
+func FromBytes(b []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+}
+
+func newHash(algorithm string) hash.Hash {
+	if algorithm == "sha512" {
+		return sha512.New()
+	}
+
+	return sha256.New()
+}
+
+type verifyingReader struct {
+	r       io.Reader
+	size    int64
+	read    int64
+	digests map[string]string
+	hashes  map[string]hash.Hash
+}
+
+// NewVerifyingReader returns a reader that hashes the content as it is read and fails
+// with a VerificationError once the content exceeds the size or, at EOF, does not match
+// all the given digests. Empty digests are ignored and a negative size skips the size
+// verification.
+func NewVerifyingReader(r io.Reader, size int64, digests ...string) (io.Reader, error) {
+	vr := &verifyingReader{
+		r:       r,
+		size:    size,
+		digests: map[string]string{},
+		hashes:  map[string]hash.Hash{},
+	}
+
+	for _, d := range digests {
+		if d == "" {
+			continue
+		}
+
+		algorithm, _, err := ParseDigest(d)
+		if err != nil {
+			return nil, err
+		}
+
+		vr.digests[d] = algorithm
+		if _, ok := vr.hashes[algorithm]; !ok {
+			vr.hashes[algorithm] = newHash(algorithm)
+		}
+	}
+
+	return vr, nil
+}
+
+func (vr *verifyingReader) Read(p []byte) (int, error) {
+	n, err := vr.r.Read(p)
+	for _, h := range vr.hashes {
+		h.Write(p[:n])
+	}
+	vr.read += int64(n)
+
+	if vr.size >= 0 && vr.read > vr.size {
+		return n, &VerificationError{Field: "size", Expected: fmt.Sprint(vr.size), Actual: fmt.Sprintf("more than %d", vr.size)}
+	}
+
+	if errors.Is(err, io.EOF) {
+		if vr.size >= 0 && vr.read != vr.size {
+			return n, &VerificationError{Field: "size", Expected: fmt.Sprint(vr.size), Actual: fmt.Sprint(vr.read)}
+		}
+
+		for d, algorithm := range vr.digests {
+			if actual := fmt.Sprintf("%s:%x", algorithm, vr.hashes[algorithm].Sum(nil)); actual != d {
+				return n, &VerificationError{Field: "digest", Expected: d, Actual: actual}
+			}
+		}
+	}
+
+	return n, err
+}
+
+// Verify checks the content against the size and the given digests
+func Verify(b []byte, size int64, digests ...string) error {
+	r, err := NewVerifyingReader(bytes.NewReader(b), size, digests...)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
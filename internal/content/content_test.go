@@ -0,0 +1,97 @@
+package content
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDigest(t *testing.T) {
+	validHex := strings.Repeat("a", 64)
+
+	algorithm, hex, err := ParseDigest("sha256:" + validHex)
+	require.NoError(t, err)
+	require.Equal(t, "sha256", algorithm)
+	require.Equal(t, validHex, hex)
+
+	for _, d := range []string{"latest", "sha256:abc123", "md5:" + validHex, "sha256:" + strings.Repeat("A", 64)} {
+		_, _, err := ParseDigest(d)
+		require.Error(t, err, d)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	content := []byte(`{"config": {}}`)
+	digest := FromBytes(content)
+	sha512Digest := fmt.Sprintf("sha512:%x", sha512.Sum512(content))
+	otherDigest := FromBytes([]byte("other"))
+
+	tests := []struct {
+		name          string
+		size          int64
+		digests       []string
+		expectedField string
+	}{
+		{
+			name:    "matching digest and size",
+			size:    int64(len(content)),
+			digests: []string{digest, "", sha512Digest},
+		},
+		{
+			name:    "unknown size",
+			size:    -1,
+			digests: []string{digest},
+		},
+		{
+			name:          "mismatching digest",
+			size:          -1,
+			digests:       []string{digest, otherDigest},
+			expectedField: "digest",
+		},
+		{
+			name:          "content bigger than size",
+			size:          int64(len(content)) - 1,
+			digests:       []string{digest},
+			expectedField: "size",
+		},
+		{
+			name:          "content smaller than size",
+			size:          int64(len(content)) + 1,
+			digests:       []string{digest},
+			expectedField: "size",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(content, tt.size, tt.digests...)
+			if tt.expectedField == "" {
+				require.NoError(t, err)
+			} else {
+				var vErr *VerificationError
+				require.ErrorAs(t, err, &vErr)
+				require.Equal(t, tt.expectedField, vErr.Field)
+			}
+		})
+	}
+}
+
+func TestVerifyingReaderInvalidDigest(t *testing.T) {
+	_, err := NewVerifyingReader(strings.NewReader(""), -1, "sha256:abc123")
+	require.Error(t, err)
+}
+
+func TestVerifyingReaderStreams(t *testing.T) {
+	content := strings.Repeat("nuro", 10000)
+
+	r, err := NewVerifyingReader(strings.NewReader(content), int64(len(content)), FromBytes([]byte(content)))
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, content, string(b))
+}
@@ -0,0 +1,199 @@
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jcchavezs/nuro/internal/api"
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/api/referrers"
+)
+
+const (
+	// SimpleSigningMediaType is the media type of the layers holding the signed payload
+	SimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// ArtifactType is the artifact type of signatures attached as referrers
+	ArtifactType = "application/vnd.dev.cosign.artifact.sig.v1+json"
+	// SignatureAnnotation is the layer annotation holding the base64 encoded signature
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	signatureType = "cosign container image signature"
+)
+
+// ErrInvalidSignature is returned when a signature does not verify against the key
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// SignatureTag returns the tag cosign stores the signatures of a digest under, e.g.
+// sha256-<hex>.sig
+func SignatureTag(digest string) string {
+	return referrers.TagSchemaTag(digest) + ".sig"
+}
+
+// Signature is a cosign signature over a simple signing payload
+type Signature struct {
+	// Source is where the signature was found, either the signature tag or a referrer
+	Source      string
+	Digest      string
+	Payload     []byte
+	Signature   []byte
+	Annotations map[string]string
+}
+
+// Payload is a simple signing payload
+type Payload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]any `json:"optional,omitempty"`
+}
+
+// Find finds the cosign signatures of the manifest with the given digest both under the
+// signature tag and in its referrers.
+func Find(ctx context.Context, registry string, insecure bool, name, digest string) ([]Signature, error) {
+	var signatures []Signature
+
+	res, err := manifest.Get(ctx, registry, insecure, name, SignatureTag(digest))
+	switch {
+	case err == nil:
+		s, err := fromManifest(ctx, registry, insecure, name, res, "tag "+SignatureTag(digest))
+		if err != nil {
+			return nil, err
+		}
+
+		signatures = append(signatures, s...)
+	case !api.IsNotFound(err):
+		return nil, fmt.Errorf("getting signature manifest: %w", err)
+	}
+
+	descriptors, err := referrers.List(ctx, registry, insecure, name, digest, ArtifactType)
+	if err != nil {
+		return nil, fmt.Errorf("listing referrers: %w", err)
+	}
+
+	for _, d := range descriptors {
+		res, err := manifest.GetByDescriptor(ctx, registry, insecure, name, d)
+		if err != nil {
+			return nil, fmt.Errorf("getting signature manifest: %w", err)
+		}
+
+		s, err := fromManifest(ctx, registry, insecure, name, res, "referrer "+d.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		signatures = append(signatures, s...)
+	}
+
+	return signatures, nil
+}
+
+func fromManifest(ctx context.Context, registry string, insecure bool, name string, res *manifest.Response, source string) ([]Signature, error) {
+	m, err := res.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("getting signature manifest: %w", err)
+	}
+
+	var signatures []Signature
+	for _, l := range m.Layers {
+		if l.MediaType != SimpleSigningMediaType {
+			continue
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(l.Annotations[SignatureAnnotation])
+		if err != nil {
+			return nil, fmt.Errorf("decoding signature: %w", err)
+		}
+
+		r, err := blob.Get(ctx, registry, insecure, name, l.Digest, l.Size)
+		if err != nil {
+			return nil, fmt.Errorf("getting signature payload: %w", err)
+		}
+
+		payload, err := io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading signature payload: %w", err)
+		}
+
+		signatures = append(signatures, Signature{
+			Source:      source,
+			Digest:      l.Digest,
+			Payload:     payload,
+			Signature:   sig,
+			Annotations: l.Annotations,
+		})
+	}
+
+	return signatures, nil
+}
+
+// LoadPublicKey loads an ECDSA or Ed25519 public key in PEM format
+func LoadPublicKey(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM block found in public key")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key: %w", err)
+	}
+
+	switch pub.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return pub, nil
+	}
+
+	return nil, fmt.Errorf("unsupported public key type %T, expected ECDSA or Ed25519", pub)
+}
+
+// Verify verifies the signature against the public key and checks the payload refers
+// to the manifest with the given digest. It returns the decoded payload.
+func (s Signature) Verify(pub crypto.PublicKey, digest string) (*Payload, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		h := sha256.Sum256(s.Payload)
+		if !ecdsa.VerifyASN1(k, h[:], s.Signature) {
+			return nil, ErrInvalidSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, s.Payload, s.Signature) {
+			return nil, ErrInvalidSignature
+		}
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	p := &Payload{}
+	if err := json.Unmarshal(s.Payload, p); err != nil {
+		return nil, fmt.Errorf("decoding payload: %w", err)
+	}
+
+	if !strings.EqualFold(p.Critical.Type, signatureType) {
+		return nil, fmt.Errorf("unexpected payload type %q", p.Critical.Type)
+	}
+
+	if p.Critical.Image.DockerManifestDigest != digest {
+		return nil, fmt.Errorf("payload refers to %s rather than %s", p.Critical.Image.DockerManifestDigest, digest)
+	}
+
+	return p, nil
+}
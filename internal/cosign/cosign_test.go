@@ -0,0 +1,148 @@
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/stretchr/testify/require"
+)
+
+const imageDigest = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+func encodePublicKey(t *testing.T, pub crypto.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func payloadFor(digest string) []byte {
+	return []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"registry.example.com/app"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, digest))
+}
+
+// serveSignature serves a signature manifest under the cosign signature tag
+func serveSignature(payload, sig []byte) *httptest.Server {
+	payloadDigest := content.FromBytes(payload)
+	m := fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.oci.image.manifest.v1+json",
+		"config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "sha256:cfg", "size": 2},
+		"layers": [{"mediaType": %q, "digest": %q, "size": %d, "annotations": {%q: %q}}]
+	}`, SimpleSigningMediaType, payloadDigest, len(payload), SignatureAnnotation, base64.StdEncoding.EncodeToString(sig))
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/app/manifests/" + SignatureTag(imageDigest):
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			_, _ = w.Write([]byte(m))
+		case "/v2/app/blobs/" + payloadDigest:
+			_, _ = w.Write(payload)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestSignatureTag(t *testing.T) {
+	require.Equal(t, "sha256-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa.sig", SignatureTag(imageDigest))
+}
+
+func TestFindAndVerify(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	ed25519Pub, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signECDSA := func(payload []byte) []byte {
+		h := sha256.Sum256(payload)
+		sig, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, h[:])
+		require.NoError(t, err)
+		return sig
+	}
+
+	tests := []struct {
+		name        string
+		payload     []byte
+		sign        func([]byte) []byte
+		pub         crypto.PublicKey
+		expectedErr bool
+	}{
+		{
+			name:    "ECDSA signature",
+			payload: payloadFor(imageDigest),
+			sign:    signECDSA,
+			pub:     &ecdsaKey.PublicKey,
+		},
+		{
+			name:    "Ed25519 signature",
+			payload: payloadFor(imageDigest),
+			sign:    func(payload []byte) []byte { return ed25519.Sign(ed25519Key, payload) },
+			pub:     ed25519Pub,
+		},
+		{
+			name:        "signature from another key",
+			payload:     payloadFor(imageDigest),
+			sign:        signECDSA,
+			pub:         &otherKey.PublicKey,
+			expectedErr: true,
+		},
+		{
+			name:        "payload for another image",
+			payload:     payloadFor("sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+			sign:        signECDSA,
+			pub:         &ecdsaKey.PublicKey,
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveSignature(tt.payload, tt.sign(tt.payload))
+			defer server.Close()
+
+			signatures, err := Find(context.Background(), server.URL[len("http://"):], true, "app", imageDigest)
+			require.NoError(t, err)
+			require.Len(t, signatures, 1)
+
+			pub, err := LoadPublicKey(encodePublicKey(t, tt.pub))
+			require.NoError(t, err)
+
+			p, err := signatures[0].Verify(pub, imageDigest)
+			if tt.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, "registry.example.com/app", p.Critical.Identity.DockerReference)
+			}
+		})
+	}
+}
+
+func TestLoadPublicKeyUnsupported(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	_, err = LoadPublicKey(encodePublicKey(t, &rsaKey.PublicKey))
+	require.Error(t, err)
+
+	_, err = LoadPublicKey([]byte("not a key"))
+	require.Error(t, err)
+}
@@ -0,0 +1,242 @@
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/jcchavezs/nuro/internal/api"
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/api/referrers"
+	"github.com/jcchavezs/nuro/internal/cache"
+	"github.com/jcchavezs/nuro/internal/content"
+)
+
+// LoadPrivateKey loads an unencrypted ECDSA or Ed25519 private key in PEM format, either
+// SEC1 or PKCS#8 encoded.
+func LoadPrivateKey(b []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM block found in private key")
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+
+		return key, nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+
+		switch k := key.(type) {
+		case *ecdsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		}
+
+		return nil, fmt.Errorf("unsupported private key type %T, expected ECDSA or Ed25519", key)
+	case "ENCRYPTED SIGSTORE PRIVATE KEY", "ENCRYPTED COSIGN PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
+		return nil, errors.New("encrypted private keys are not supported, export the key unencrypted (e.g. with openssl) first")
+	}
+
+	return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+}
+
+// NewPayload builds the simple signing payload for the manifest with the given digest
+func NewPayload(dockerReference, digest string, annotations map[string]string) ([]byte, error) {
+	p := Payload{}
+	p.Critical.Identity.DockerReference = dockerReference
+	p.Critical.Image.DockerManifestDigest = digest
+	p.Critical.Type = signatureType
+
+	if len(annotations) > 0 {
+		p.Optional = make(map[string]any, len(annotations))
+		for k, v := range annotations {
+			p.Optional[k] = v
+		}
+	}
+
+	return json.Marshal(p)
+}
+
+// Sign signs the payload with the key the same way cosign does, that is ECDSA over the
+// SHA256 of the payload and Ed25519 over the payload itself.
+func Sign(key crypto.Signer, payload []byte) ([]byte, error) {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		h := sha256.Sum256(payload)
+		return ecdsa.SignASN1(rand.Reader, k, h[:])
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, payload), nil
+	}
+
+	return nil, fmt.Errorf("unsupported private key type %T", key)
+}
+
+// PushOptions configures how a signature is pushed
+type PushOptions struct {
+	// Referrer attaches the signature as a referrer of the image rather than under the
+	// signature tag.
+	Referrer bool
+	// Subject is the descriptor of the signed manifest, required when pushing as a referrer
+	Subject manifest.Descriptor
+}
+
+// Push uploads the payload and pushes the signature manifest, either appending the
+// signature to the ones under the signature tag or as a new referrer of the subject. It
+// returns the reference of the pushed manifest.
+func Push(ctx context.Context, registry string, insecure bool, name, digest string, payload, sig []byte, opts PushOptions) (string, error) {
+	payloadDigest, err := blob.Upload(ctx, registry, insecure, name, payload)
+	if err != nil {
+		return "", fmt.Errorf("uploading payload: %w", err)
+	}
+
+	layer := manifest.Descriptor{
+		MediaType:   SimpleSigningMediaType,
+		Digest:      payloadDigest,
+		Size:        int64(len(payload)),
+		Annotations: map[string]string{SignatureAnnotation: base64.StdEncoding.EncodeToString(sig)},
+	}
+
+	if opts.Referrer {
+		return pushReferrer(ctx, registry, insecure, name, layer, opts.Subject)
+	}
+
+	return pushTag(ctx, registry, insecure, name, digest, layer)
+}
+
+func pushTag(ctx context.Context, registry string, insecure bool, name, digest string, layer manifest.Descriptor) (string, error) {
+	tag := SignatureTag(digest)
+
+	// the cached signature manifest could be stale and its signatures lost when pushing
+	var layers []manifest.Descriptor
+	res, err := manifest.Get(cache.WithoutCache(ctx), registry, insecure, name, tag)
+	switch {
+	case err == nil:
+		m, err := res.Manifest()
+		if err != nil {
+			return "", fmt.Errorf("getting signature manifest: %w", err)
+		}
+
+		layers = m.Layers
+	case !api.IsNotFound(err):
+		return "", fmt.Errorf("getting signature manifest: %w", err)
+	}
+
+	for _, l := range layers {
+		if l.Digest == layer.Digest && l.Annotations[SignatureAnnotation] == layer.Annotations[SignatureAnnotation] {
+			return tag, nil
+		}
+	}
+	layers = append(layers, layer)
+
+	// cosign stores an image config listing the payloads as the root filesystem
+	diffIDs := make([]string, 0, len(layers))
+	for _, l := range layers {
+		diffIDs = append(diffIDs, l.Digest)
+	}
+
+	cfg, err := json.Marshal(map[string]any{
+		"architecture": "",
+		"os":           "",
+		"config":       map[string]any{},
+		"rootfs":       map[string]any{"type": "layers", "diff_ids": diffIDs},
+	})
+	if err != nil {
+		return "", fmt.Errorf("encoding config: %w", err)
+	}
+
+	config, err := uploadConfig(ctx, registry, insecure, name, manifest.OCIConfigV1ContentType, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(manifest.Manifest{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIManifestV1ContentType,
+		Config:        config,
+		Layers:        layers,
+	})
+	if err != nil {
+		return "", fmt.Errorf("encoding signature manifest: %w", err)
+	}
+
+	if _, err := manifest.Put(ctx, registry, insecure, name, tag, manifest.OCIManifestV1ContentType, b); err != nil {
+		return "", fmt.Errorf("pushing signature manifest: %w", err)
+	}
+
+	return tag, nil
+}
+
+func pushReferrer(ctx context.Context, registry string, insecure bool, name string, layer, subject manifest.Descriptor) (string, error) {
+	config, err := uploadConfig(ctx, registry, insecure, name, manifest.OCIEmptyContentType, []byte("{}"))
+	if err != nil {
+		return "", err
+	}
+
+	m := manifest.Manifest{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIManifestV1ContentType,
+		ArtifactType:  ArtifactType,
+		Config:        config,
+		Layers:        []manifest.Descriptor{layer},
+		Subject: &manifest.Descriptor{
+			MediaType: subject.MediaType,
+			Digest:    subject.Digest,
+			Size:      subject.Size,
+		},
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("encoding signature manifest: %w", err)
+	}
+
+	d := manifest.Descriptor{
+		MediaType:    manifest.OCIManifestV1ContentType,
+		Digest:       content.FromBytes(b),
+		Size:         int64(len(b)),
+		ArtifactType: ArtifactType,
+	}
+
+	res, err := manifest.Put(ctx, registry, insecure, name, d.Digest, d.MediaType, b)
+	if err != nil {
+		return "", fmt.Errorf("pushing signature manifest: %w", err)
+	}
+
+	// registries without the referrers API do not report the subject, in which case the
+	// referrers index under the tag schema has to be maintained by the client.
+	if res.Subject == "" {
+		if err := referrers.AddToTagSchema(ctx, registry, insecure, name, subject.Digest, d); err != nil {
+			return "", err
+		}
+	}
+
+	return d.Digest, nil
+}
+
+func uploadConfig(ctx context.Context, registry string, insecure bool, name, mediaType string, b []byte) (manifest.Descriptor, error) {
+	digest, err := blob.Upload(ctx, registry, insecure, name, b)
+	if err != nil {
+		return manifest.Descriptor{}, fmt.Errorf("uploading config: %w", err)
+	}
+
+	return manifest.Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(b))}, nil
+}
@@ -0,0 +1,149 @@
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRegistry is a minimal registry without referrers API support that stores
+// pushed blobs and manifests in memory
+type memoryRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	types     map[string]string
+}
+
+func newMemoryRegistry() *memoryRegistry {
+	return &memoryRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}, types: map[string]string{}}
+}
+
+func (reg *memoryRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/app/")
+	switch {
+	case r.Method == http.MethodPost && path == "blobs/uploads/":
+		w.Header().Set("Location", "/v2/app/blobs/uploads/session")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && path == "blobs/uploads/session":
+		b, _ := io.ReadAll(r.Body)
+		reg.blobs[r.URL.Query().Get("digest")] = b
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(path, "blobs/"):
+		b, ok := reg.blobs[strings.TrimPrefix(path, "blobs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(b)
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "manifests/"):
+		b, _ := io.ReadAll(r.Body)
+		ref := strings.TrimPrefix(path, "manifests/")
+		reg.manifests[ref] = b
+		reg.types[ref] = r.Header.Get("Content-Type")
+		w.Header().Set("Docker-Content-Digest", content.FromBytes(b))
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(path, "manifests/"):
+		ref := strings.TrimPrefix(path, "manifests/")
+		b, ok := reg.manifests[ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", reg.types[ref])
+		_, _ = w.Write(b)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sec1, err := x509.MarshalECPrivateKey(ecdsaKey)
+	require.NoError(t, err)
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(ed25519Key)
+	require.NoError(t, err)
+
+	_, err = LoadPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}))
+	require.NoError(t, err)
+
+	_, err = LoadPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+	require.NoError(t, err)
+
+	_, err = LoadPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED SIGSTORE PRIVATE KEY", Bytes: []byte("x")}))
+	require.Error(t, err)
+}
+
+func TestSignAndPush(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	subject := manifest.Descriptor{MediaType: manifest.OCIManifestV1ContentType, Digest: imageDigest, Size: 100}
+
+	tests := []struct {
+		name     string
+		referrer bool
+	}{
+		{name: "signature tag"},
+		{name: "referrer", referrer: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := newMemoryRegistry()
+			server := httptest.NewServer(reg)
+			defer server.Close()
+
+			registry := server.URL[len("http://"):]
+			ctx := context.Background()
+
+			// signing twice with different annotations keeps both signatures
+			for _, env := range []string{"staging", "prod"} {
+				payload, err := NewPayload("registry.example.com/app", imageDigest, map[string]string{"env": env})
+				require.NoError(t, err)
+
+				sig, err := Sign(key, payload)
+				require.NoError(t, err)
+
+				_, err = Push(ctx, registry, true, "app", imageDigest, payload, sig, PushOptions{Referrer: tt.referrer, Subject: subject})
+				require.NoError(t, err)
+			}
+
+			signatures, err := Find(ctx, registry, true, "app", imageDigest)
+			require.NoError(t, err)
+			require.Len(t, signatures, 2)
+
+			for _, s := range signatures {
+				p, err := s.Verify(&key.PublicKey, imageDigest)
+				require.NoError(t, err)
+				require.Equal(t, "registry.example.com/app", p.Critical.Identity.DockerReference)
+			}
+
+			_, tagged := reg.manifests[SignatureTag(imageDigest)]
+			require.Equal(t, !tt.referrer, tagged)
+		})
+	}
+}
@@ -4,15 +4,31 @@ import (
 	"net/http"
 
 	"github.com/jcchavezs/nuro/internal/auth"
+	"github.com/jcchavezs/nuro/internal/cache"
 	"github.com/jcchavezs/nuro/internal/log"
 )
 
 var Client = &http.Client{
 	Transport: log.WrapRoundTripper(
-		auth.WrapRoundTripper(http.DefaultTransport),
+		cache.WrapRoundTripper(
+			auth.WrapRoundTripper(http.DefaultTransport),
+		),
 	),
+	CheckRedirect: checkRedirect,
 }
 
+type Request = http.Request
+
+type Response = http.Response
+
 var NewRequestWithContext = http.NewRequestWithContext
 
-const StatusOK = http.StatusOK
+const (
+	StatusOK       = http.StatusOK
+	StatusCreated  = http.StatusCreated
+	StatusAccepted = http.StatusAccepted
+	StatusNotFound = http.StatusNotFound
+
+	StatusPartialContent               = http.StatusPartialContent
+	StatusRequestedRangeNotSatisfiable = http.StatusRequestedRangeNotSatisfiable
+)
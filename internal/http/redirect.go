@@ -0,0 +1,49 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jcchavezs/nuro/internal/log"
+	"github.com/yuseferi/zax/v2"
+	"go.uber.org/zap"
+)
+
+// maxRedirects is the maximum number of redirects followed for a single request, registries
+// usually redirect blob downloads once to the object storage.
+const maxRedirects = 5
+
+// sensitiveHeaders are the headers holding credentials for the registry
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// checkRedirect limits the number of redirects and strips the registry credentials when
+// the redirect leaves the registry host, as object storages reject or log foreign
+// credentials.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	fields := []zap.Field{
+		zap.String("from", redactedURL(via[len(via)-1])),
+		zap.String("to", redactedURL(req)),
+	}
+	fields = append(fields, zax.Get(req.Context())...)
+	log.Logger.Debug("Following redirect", fields...)
+
+	if req.URL.Host != via[0].URL.Host {
+		for _, h := range sensitiveHeaders {
+			req.Header.Del(h)
+		}
+	}
+
+	return nil
+}
+
+// redactedURL returns the URL of the request without the query, as redirects to object
+// storages usually carry presigned credentials in it.
+func redactedURL(req *http.Request) string {
+	u := *req.URL
+	u.RawQuery = ""
+	return u.Redacted()
+}
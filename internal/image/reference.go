@@ -0,0 +1,20 @@
+package image
+
+// FormatReference returns the fully qualified reference of an image in the
+// form registry/name[:tag][@digest]
+func FormatReference(registry, name, tag, digest string) string {
+	if registry == DockerRegistry {
+		registry = "docker.io"
+	}
+
+	ref := registry + "/" + name
+	if tag != "" {
+		ref += ":" + tag
+	}
+
+	if digest != "" {
+		ref += "@" + digest
+	}
+
+	return ref
+}
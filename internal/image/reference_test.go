@@ -0,0 +1,55 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatReference(t *testing.T) {
+	tests := []struct {
+		name        string
+		registry    string
+		imageName   string
+		tag         string
+		digest      string
+		expectedRef string
+	}{
+		{
+			name:        "docker registry with tag and digest",
+			registry:    DockerRegistry,
+			imageName:   "library/nginx",
+			tag:         "latest",
+			digest:      "sha256:abc123",
+			expectedRef: "docker.io/library/nginx:latest@sha256:abc123",
+		},
+		{
+			name:        "custom registry with digest only",
+			registry:    "myregistry.com",
+			imageName:   "library/nginx",
+			digest:      "sha256:abc123",
+			expectedRef: "myregistry.com/library/nginx@sha256:abc123",
+		},
+		{
+			name:        "custom registry with tag only",
+			registry:    "myregistry.com",
+			imageName:   "library/nginx",
+			tag:         "1.19",
+			expectedRef: "myregistry.com/library/nginx:1.19",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := FormatReference(tt.registry, tt.imageName, tt.tag, tt.digest)
+			require.Equal(t, tt.expectedRef, ref)
+
+			reg, name, tag, digest, err := ParseImage(ref)
+			require.NoError(t, err)
+			require.Equal(t, tt.registry, reg)
+			require.Equal(t, tt.imageName, name)
+			require.Equal(t, tt.tag, tag)
+			require.Equal(t, tt.digest, digest)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package layer
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+)
+
+// lookup is the outcome of looking for a path in a single layer
+type lookup struct {
+	entry *Entry
+	// redirect is the path to look for instead, as the path or one of its parents is a link
+	redirect string
+	hardlink bool
+	// hidden means the path does not exist in this layer nor in the ones below
+	hidden bool
+	err    error
+}
+
+// Extract writes the content of the file at p to w. Layers are scanned from the top
+// down, stopping at the first one that contains the file or removes it, so only the
+// layers above the file are downloaded. Symlinks are followed within the image root.
+// The content is written as it is read, the layer is verified once read until the end.
+func Extract(ctx context.Context, registry string, insecure bool, name string, layers []manifest.Descriptor, p string, w io.Writer) (*Entry, error) {
+	p = CleanPath(p)
+	if p == "/" {
+		return nil, fmt.Errorf("%s: is a directory", p)
+	}
+
+	hops := 0
+	// dirs are the parents of p found as directories in the layers already scanned,
+	// which hide whatever the lower layers have at those paths
+	dirs := map[string]bool{}
+	for i := len(layers) - 1; i >= 0; {
+		var l lookup
+		err := Read(ctx, registry, insecure, name, layers[i], func(h *tar.Header, r io.Reader) error {
+			if l.entry != nil || l.redirect != "" || l.err != nil {
+				return nil
+			}
+
+			l.find(p, h, r, layers[i].Digest, dirs, w)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case l.err != nil:
+			return nil, l.err
+		case l.entry != nil:
+			return l.entry, nil
+		case l.redirect != "":
+			if hops++; hops > maxSymlinks {
+				return nil, fmt.Errorf("%s: %w", p, ErrTooManySymlinks)
+			}
+
+			p, dirs = l.redirect, map[string]bool{}
+			if !l.hardlink {
+				// hardlink targets are always in the same layer or below
+				i = len(layers) - 1
+			}
+		case l.hidden:
+			return nil, fmt.Errorf("%s: %w", p, fs.ErrNotExist)
+		default:
+			i--
+		}
+	}
+
+	return nil, fmt.Errorf("%s: %w", p, fs.ErrNotExist)
+}
+
+// find checks whether the entry is the file at p, one of its parents or a whiteout
+// removing any of them, writing the content of the file to w when found.
+func (l *lookup) find(p string, h *tar.Header, r io.Reader, layer string, dirs map[string]bool, w io.Writer) {
+	hp := CleanPath(h.Name)
+
+	if target, opaque, ok := whiteout(hp); ok {
+		// whiteouts only apply to the lower layers, the file can still be in this one
+		if isParent(target, p) || (!opaque && target == p) {
+			l.hidden = true
+		}
+		return
+	}
+
+	e, ok := newEntry(hp, h, layer)
+	if !ok {
+		return
+	}
+
+	switch {
+	case hp == p:
+		switch e.Type {
+		case TypeFile:
+			if _, err := io.Copy(w, r); err != nil {
+				l.err = fmt.Errorf("writing %s: %w", p, err)
+				return
+			}
+			l.entry = e
+		case TypeSymlink:
+			l.redirect = resolveLink(hp, e.Linkname)
+		case TypeHardlink:
+			l.redirect, l.hardlink = e.Linkname, true
+		case TypeDir:
+			l.err = fmt.Errorf("%s: is a directory", p)
+		default:
+			l.err = fmt.Errorf("%s: not a regular file", p)
+		}
+	case isParent(hp, p):
+		switch {
+		case e.Type == TypeDir:
+			dirs[hp] = true
+		case dirs[hp]:
+			// replaced by a directory in an upper layer
+			l.hidden = true
+		case e.Type == TypeSymlink:
+			l.redirect = path.Join(resolveLink(hp, e.Linkname), strings.TrimPrefix(p, hp+"/"))
+		default:
+			l.hidden = true
+		}
+	}
+}
+
+// isParent returns whether dir is one of the parent directories of p
+func isParent(dir, p string) bool {
+	return dir != "/" && strings.HasPrefix(p, dir+"/")
+}
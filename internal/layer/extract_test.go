@@ -0,0 +1,136 @@
+package layer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/stretchr/testify/require"
+)
+
+type testFile struct {
+	header  *tar.Header
+	content string
+}
+
+func layerOf(t *testing.T, files ...testFile) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		f.header.Size = int64(len(f.content))
+		require.NoError(t, tw.WriteHeader(f.header))
+		_, err := tw.Write([]byte(f.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	blobs := [][]byte{
+		layerOf(t,
+			testFile{header: dir("etc/")},
+			testFile{header: file("etc/os-release", 0), content: "ID=base\n"},
+			testFile{header: file("etc/hostname", 0), content: "base\n"},
+			testFile{header: dir("usr/bin/")},
+			testFile{header: file("usr/bin/app", 0), content: "app v1"},
+			testFile{header: dir("opt/data/")},
+			testFile{header: file("opt/data/a", 0), content: "a"},
+			testFile{header: symlink("lib", "usr/lib")},
+			testFile{header: dir("usr/lib/")},
+			testFile{header: file("usr/lib/libc.so", 0), content: "libc"},
+		),
+		layerOf(t,
+			testFile{header: symlink("bin", "usr/bin")},
+			testFile{header: file("usr/bin/app", 0), content: "app v2"},
+			testFile{header: &tar.Header{Name: "usr/bin/app2", Typeflag: tar.TypeLink, Linkname: "usr/bin/app", Mode: 0o755}},
+			testFile{header: file("etc/.wh.hostname", 0)},
+			testFile{header: file("opt/data/.wh..wh..opq", 0)},
+			testFile{header: file("opt/data/b", 0), content: "b"},
+			testFile{header: dir("lib/")},
+			testFile{header: file("lib/own.so", 0), content: "own"},
+		),
+		layerOf(t,
+			testFile{header: file("etc/motd", 0), content: "hello"},
+			testFile{header: symlink("etc/current", "../usr/bin/app")},
+			testFile{header: symlink("loop", "/loop")},
+		),
+	}
+
+	var layers []manifest.Descriptor
+	byDigest := map[string][]byte{}
+	for _, b := range blobs {
+		d := content.FromBytes(b)
+		byDigest[d] = b
+		layers = append(layers, manifest.Descriptor{
+			MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+			Digest:    d,
+			Size:      int64(len(b)),
+		})
+	}
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write(byDigest[path.Base(r.URL.Path)])
+	}))
+	defer server.Close()
+
+	registry := server.URL[len("http://"):]
+
+	tests := []struct {
+		name             string
+		path             string
+		expectedContent  string
+		expectedPath     string
+		expectedRequests int
+		expectedErr      error
+	}{
+		{name: "file in the top layer", path: "/etc/motd", expectedContent: "hello", expectedPath: "/etc/motd", expectedRequests: 1},
+		{name: "relative path", path: "etc/os-release", expectedContent: "ID=base\n", expectedPath: "/etc/os-release", expectedRequests: 3},
+		{name: "file overridden", path: "/usr/bin/app", expectedContent: "app v2", expectedPath: "/usr/bin/app", expectedRequests: 2},
+		{name: "symlink", path: "/etc/current", expectedContent: "app v2", expectedPath: "/usr/bin/app", expectedRequests: 3},
+		{name: "symlink in parent", path: "/bin/app", expectedContent: "app v2", expectedPath: "/usr/bin/app", expectedRequests: 4},
+		{name: "hardlink", path: "/usr/bin/app2", expectedContent: "app v2", expectedPath: "/usr/bin/app", expectedRequests: 3},
+		{name: "file added to an opaque directory", path: "/opt/data/b", expectedContent: "b", expectedPath: "/opt/data/b", expectedRequests: 2},
+		{name: "removed by whiteout", path: "/etc/hostname", expectedErr: fs.ErrNotExist},
+		{name: "removed by opaque directory", path: "/opt/data/a", expectedErr: fs.ErrNotExist},
+		{name: "symlink replaced by a directory", path: "/lib/libc.so", expectedErr: fs.ErrNotExist},
+		{name: "missing", path: "/missing", expectedErr: fs.ErrNotExist},
+		{name: "symlink loop", path: "/loop", expectedErr: ErrTooManySymlinks},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+
+			var buf bytes.Buffer
+			e, err := Extract(context.Background(), registry, true, "test/img", layers, tt.path, &buf)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedContent, buf.String())
+			require.Equal(t, tt.expectedPath, e.Path)
+			require.Equal(t, tt.expectedRequests, requests)
+		})
+	}
+
+	t.Run("directory", func(t *testing.T) {
+		_, err := Extract(context.Background(), registry, true, "test/img", layers, "/etc", &bytes.Buffer{})
+		require.ErrorContains(t, err, "is a directory")
+	})
+}
@@ -0,0 +1,312 @@
+package layer
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+)
+
+// maxSymlinks is the maximum number of symlinks followed when resolving a path, as in Linux
+const maxSymlinks = 40
+
+// ErrTooManySymlinks is returned when resolving a path follows more than maxSymlinks symlinks
+var ErrTooManySymlinks = errors.New("too many levels of symbolic links")
+
+// Type is the type of a file
+type Type string
+
+const (
+	TypeFile     Type = "file"
+	TypeDir      Type = "dir"
+	TypeSymlink  Type = "symlink"
+	TypeHardlink Type = "hardlink"
+	TypeChar     Type = "char"
+	TypeBlock    Type = "block"
+	TypeFifo     Type = "fifo"
+)
+
+var types = map[byte]Type{
+	tar.TypeReg:     TypeFile,
+	tar.TypeRegA:    TypeFile, //nolint:staticcheck
+	tar.TypeDir:     TypeDir,
+	tar.TypeSymlink: TypeSymlink,
+	tar.TypeLink:    TypeHardlink,
+	tar.TypeChar:    TypeChar,
+	tar.TypeBlock:   TypeBlock,
+	tar.TypeFifo:    TypeFifo,
+}
+
+// Entry is a file in the merged filesystem of an image
+type Entry struct {
+	Path string
+	Type Type
+	// Mode holds the permission bits including setuid, setgid and sticky
+	Mode    int64
+	UID     int
+	GID     int
+	User    string
+	Group   string
+	Size    int64
+	ModTime time.Time
+	// Linkname is the target of symlinks as written in the layer and the absolute path
+	// of the target of hardlinks
+	Linkname string
+	// Layer is the digest of the layer the file comes from
+	Layer string
+}
+
+func newEntry(p string, h *tar.Header, layer string) (*Entry, bool) {
+	t, ok := types[h.Typeflag]
+	if !ok {
+		// e.g. extended headers already merged by the tar reader
+		return nil, false
+	}
+
+	e := &Entry{
+		Path:     p,
+		Type:     t,
+		Mode:     h.Mode & 0o7777,
+		UID:      h.Uid,
+		GID:      h.Gid,
+		User:     h.Uname,
+		Group:    h.Gname,
+		Size:     h.Size,
+		ModTime:  h.ModTime,
+		Linkname: h.Linkname,
+		Layer:    layer,
+	}
+
+	if t == TypeHardlink {
+		e.Linkname = CleanPath(h.Linkname)
+		e.Size = 0
+	}
+
+	return e, true
+}
+
+type node struct {
+	entry *Entry
+	// children is nil for anything but directories
+	children map[string]*node
+}
+
+func newNode(e *Entry) *node {
+	n := &node{entry: e}
+	if e.Type == TypeDir {
+		n.children = map[string]*node{}
+	}
+
+	return n
+}
+
+// FS is the filesystem resulting of applying the layers of an image on top of each other
+type FS struct {
+	root *node
+}
+
+func newFS() *FS {
+	return &FS{root: newNode(&Entry{Path: "/", Type: TypeDir, Mode: 0o755})}
+}
+
+// Merge reads the layers in bottom to top order and merges them into a single
+// filesystem, applying whiteouts and opaque directories.
+func Merge(ctx context.Context, registry string, insecure bool, name string, layers []manifest.Descriptor) (*FS, error) {
+	f := newFS()
+	for _, l := range layers {
+		var headers []*tar.Header
+		err := Read(ctx, registry, insecure, name, l, func(h *tar.Header, _ io.Reader) error {
+			headers = append(headers, h)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		f.apply(l.Digest, headers)
+	}
+
+	return f, nil
+}
+
+// apply applies a layer on top of the filesystem. Whiteouts only remove content from the
+// lower layers, no matter where they appear in the archive.
+func (f *FS) apply(layer string, headers []*tar.Header) {
+	for _, h := range headers {
+		p, opaque, ok := whiteout(CleanPath(h.Name))
+		if !ok {
+			continue
+		}
+
+		n := f.lookup(p)
+		switch {
+		case n == nil:
+		case opaque:
+			if n.children != nil {
+				n.children = map[string]*node{}
+			}
+		default:
+			delete(f.lookup(path.Dir(p)).children, path.Base(p))
+		}
+	}
+
+	for _, h := range headers {
+		p := CleanPath(h.Name)
+		if dir, opaque, ok := whiteout(p); ok {
+			if opaque {
+				f.mkdirAll(dir, layer, h.ModTime)
+			}
+			continue
+		}
+
+		if p == "/" {
+			continue
+		}
+
+		e, ok := newEntry(p, h, layer)
+		if !ok {
+			continue
+		}
+
+		parent := f.mkdirAll(path.Dir(p), layer, h.ModTime)
+		if existing, ok := parent.children[path.Base(p)]; ok && existing.children != nil && e.Type == TypeDir {
+			// directories only update their metadata, the content of lower layers remains
+			existing.entry = e
+			continue
+		}
+
+		parent.children[path.Base(p)] = newNode(e)
+	}
+}
+
+// mkdirAll returns the directory at p creating it and its parents when missing, as
+// archives are not required to include the parents of their entries. Non directories
+// in the way are replaced.
+func (f *FS) mkdirAll(p, layer string, modTime time.Time) *node {
+	n := f.root
+	for _, part := range split(p) {
+		child, ok := n.children[part]
+		if !ok || child.children == nil {
+			child = newNode(&Entry{
+				Path:    path.Join(n.entry.Path, part),
+				Type:    TypeDir,
+				Mode:    0o755,
+				ModTime: modTime,
+				Layer:   layer,
+			})
+			n.children[part] = child
+		}
+
+		n = child
+	}
+
+	return n
+}
+
+// lookup returns the node at p without following symlinks or nil when missing
+func (f *FS) lookup(p string) *node {
+	n := f.root
+	for _, part := range split(p) {
+		if n = n.children[part]; n == nil {
+			return nil
+		}
+	}
+
+	return n
+}
+
+func split(p string) []string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
+
+// resolve returns the node at p following the symlinks in its parent directories and,
+// when followLast is true, the symlink at p itself.
+func (f *FS) resolve(p string, followLast bool) (*node, error) {
+	hops := 0
+	parts := split(p)
+	n := f.root
+	for i := 0; i < len(parts); i++ {
+		if n.children == nil {
+			return nil, fmt.Errorf("%s: not a directory", n.entry.Path)
+		}
+
+		child, ok := n.children[parts[i]]
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", path.Join(n.entry.Path, parts[i]), fs.ErrNotExist)
+		}
+
+		last := i == len(parts)-1
+		if child.entry.Type == TypeSymlink && (!last || followLast) {
+			if hops++; hops > maxSymlinks {
+				return nil, fmt.Errorf("%s: %w", p, ErrTooManySymlinks)
+			}
+
+			target := resolveLink(child.entry.Path, child.entry.Linkname)
+			parts = append(split(target), parts[i+1:]...)
+			n, i = f.root, -1
+			continue
+		}
+
+		n = child
+	}
+
+	return n, nil
+}
+
+// Stat returns the entry at p following symlinks in its parent directories only
+func (f *FS) Stat(p string) (*Entry, error) {
+	n, err := f.resolve(p, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.entry, nil
+}
+
+// List lists the entries in the directory at p sorted by path, descending into the
+// subdirectories when recursive is true. A symlink at p is followed only when
+// followLast is true. When p is not a directory only its entry is returned.
+func (f *FS) List(p string, recursive, followLast bool) ([]*Entry, error) {
+	n, err := f.resolve(p, followLast)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.children == nil {
+		return []*Entry{n.entry}, nil
+	}
+
+	var entries []*Entry
+	list(n, recursive, &entries)
+
+	return entries, nil
+}
+
+func list(n *node, recursive bool, entries *[]*Entry) {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := n.children[name]
+		*entries = append(*entries, child.entry)
+		if recursive && child.children != nil {
+			list(child, recursive, entries)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package layer
+
+import (
+	"archive/tar"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func dir(name string) *tar.Header {
+	return &tar.Header{Name: name, Typeflag: tar.TypeDir, Mode: 0o755}
+}
+
+func file(name string, size int64) *tar.Header {
+	return &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: size}
+}
+
+func symlink(name, target string) *tar.Header {
+	return &tar.Header{Name: name, Typeflag: tar.TypeSymlink, Mode: 0o777, Linkname: target}
+}
+
+func paths(entries []*Entry) []string {
+	ps := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ps = append(ps, e.Path)
+	}
+
+	return ps
+}
+
+func testFS() *FS {
+	f := newFS()
+	f.apply("sha256:base", []*tar.Header{
+		dir("etc/"),
+		file("etc/os-release", 10),
+		file("etc/passwd", 20),
+		dir("usr/"),
+		dir("usr/bin/"),
+		file("usr/bin/sh", 30),
+		symlink("bin", "usr/bin"),
+		dir("opt/app/"),
+		file("opt/app/config.yaml", 40),
+		file("opt/app/old.yaml", 50),
+		dir("var/cache/"),
+		file("var/cache/a", 1),
+	})
+	f.apply("sha256:top", []*tar.Header{
+		file("./etc/passwd", 25),
+		file("etc/.wh.os-release", 0),
+		file("opt/app/.wh..wh..opq", 0),
+		file("opt/app/config.yaml", 45),
+		file("var/.wh.cache", 0),
+		file("var/cache", 2),
+		symlink("usr/bin/bash", "/usr/bin/sh"),
+		symlink("loop", "loop"),
+	})
+
+	return f
+}
+
+func TestApply(t *testing.T) {
+	f := testFS()
+
+	entries, err := f.List("/", true, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"/bin",
+		"/etc",
+		"/etc/passwd",
+		"/loop",
+		"/opt",
+		"/opt/app",
+		"/opt/app/config.yaml",
+		"/usr",
+		"/usr/bin",
+		"/usr/bin/bash",
+		"/usr/bin/sh",
+		"/var",
+		"/var/cache",
+	}, paths(entries))
+
+	passwd, err := f.Stat("/etc/passwd")
+	require.NoError(t, err)
+	require.Equal(t, int64(25), passwd.Size)
+	require.Equal(t, "sha256:top", passwd.Layer)
+
+	etc, err := f.Stat("/etc")
+	require.NoError(t, err)
+	require.Equal(t, "sha256:base", etc.Layer)
+
+	cache, err := f.Stat("/var/cache")
+	require.NoError(t, err)
+	require.Equal(t, TypeFile, cache.Type)
+}
+
+func TestList(t *testing.T) {
+	f := testFS()
+
+	t.Run("directory", func(t *testing.T) {
+		entries, err := f.List("/usr/bin", false, false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/usr/bin/bash", "/usr/bin/sh"}, paths(entries))
+	})
+
+	t.Run("file", func(t *testing.T) {
+		entries, err := f.List("/etc/passwd", false, false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/etc/passwd"}, paths(entries))
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		entries, err := f.List("/bin", false, false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/bin"}, paths(entries))
+	})
+
+	t.Run("followed symlink", func(t *testing.T) {
+		entries, err := f.List("/bin", false, true)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/usr/bin/bash", "/usr/bin/sh"}, paths(entries))
+	})
+
+	t.Run("symlink in parent", func(t *testing.T) {
+		e, err := f.Stat("/bin/sh")
+		require.NoError(t, err)
+		require.Equal(t, "/usr/bin/sh", e.Path)
+	})
+
+	t.Run("removed by whiteout", func(t *testing.T) {
+		_, err := f.List("/etc/os-release", false, false)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("symlink loop", func(t *testing.T) {
+		_, err := f.List("/loop", false, true)
+		require.ErrorIs(t, err, ErrTooManySymlinks)
+	})
+}
+
+func TestResolveLink(t *testing.T) {
+	require.Equal(t, "/usr/bin/sh", resolveLink("/bin/sh", "../usr/bin/sh"))
+	require.Equal(t, "/etc/passwd", resolveLink("/bin/sh", "/etc/passwd"))
+	require.Equal(t, "/etc/passwd", resolveLink("/bin/sh", "../../../etc/passwd"))
+}
@@ -0,0 +1,92 @@
+package layer
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+)
+
+const (
+	// whiteoutPrefix marks a file that removes the file with the same name from the
+	// lower layers, see https://github.com/opencontainers/image-spec/blob/main/layer.md#whiteouts
+	whiteoutPrefix = ".wh."
+	// opaqueWhiteout marks a directory whose content in the lower layers is removed
+	opaqueWhiteout = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+// Read streams the layer and calls fn for every entry of its tar archive in order. The
+// layer is read until the end so its content is verified even when fn does not read the
+// entries.
+func Read(ctx context.Context, registry string, insecure bool, name string, d manifest.Descriptor, fn func(h *tar.Header, r io.Reader) error) error {
+	r, err := blob.Get(ctx, registry, insecure, name, d.Digest, d.Size)
+	if err != nil {
+		return fmt.Errorf("getting layer %s: %w", d.Digest, err)
+	}
+	defer r.Close() //nolint
+
+	dr, err := blob.Decompress(r, d.MediaType)
+	if err != nil {
+		return fmt.Errorf("decompressing layer %s: %w", d.Digest, err)
+	}
+	defer dr.Close() //nolint
+
+	tr := tar.NewReader(dr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("reading layer %s: %w", d.Digest, err)
+		}
+
+		if err := fn(h, tr); err != nil {
+			return err
+		}
+	}
+
+	// the archive can be followed by padding which is part of the digest
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return fmt.Errorf("reading layer %s: %w", d.Digest, err)
+	}
+
+	return nil
+}
+
+// CleanPath returns the absolute path of an entry in a tar archive, which can be relative
+// or start with ./
+func CleanPath(name string) string {
+	return path.Join("/", name)
+}
+
+// whiteout returns the path removed by a whiteout entry and whether it removes only the
+// content of the directory.
+func whiteout(p string) (string, bool, bool) {
+	dir, base := path.Split(p)
+	if base == opaqueWhiteout {
+		return path.Clean(dir), true, true
+	}
+
+	if strings.HasPrefix(base, whiteoutPrefix) {
+		return path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)), false, true
+	}
+
+	return "", false, false
+}
+
+// resolveLink returns the path a symlink at linkPath points to. Targets are resolved
+// within the image root so they never point outside of it.
+func resolveLink(linkPath, target string) string {
+	if path.IsAbs(target) {
+		return path.Clean(target)
+	}
+
+	return path.Join(path.Dir(linkPath), target)
+}
@@ -0,0 +1,69 @@
+package layer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipTar(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, c := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(c))}))
+		_, err := tw.Write([]byte(c))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	layer := gzipTar(t, map[string]string{"etc/os-release": "ID=test\n"})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(layer)
+	}))
+	defer server.Close()
+
+	registry := server.URL[len("http://"):]
+
+	t.Run("valid layer", func(t *testing.T) {
+		d := manifest.Descriptor{
+			MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+			Digest:    content.FromBytes(layer),
+			Size:      int64(len(layer)),
+		}
+
+		files := map[string]string{}
+		err := Read(context.Background(), registry, true, "test/img", d, func(h *tar.Header, r io.Reader) error {
+			b, err := io.ReadAll(r)
+			files[CleanPath(h.Name)] = string(b)
+			return err
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"/etc/os-release": "ID=test\n"}, files)
+	})
+
+	t.Run("layer not matching the digest", func(t *testing.T) {
+		d := manifest.Descriptor{
+			MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+			Digest:    content.FromBytes([]byte("other")),
+		}
+
+		err := Read(context.Background(), registry, true, "test/img", d, func(*tar.Header, io.Reader) error { return nil })
+		require.Error(t, err)
+	})
+}
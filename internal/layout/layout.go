@@ -0,0 +1,362 @@
+package layout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/image"
+	"github.com/jcchavezs/nuro/internal/log"
+	"go.uber.org/zap"
+)
+
+// see https://github.com/opencontainers/image-spec/blob/main/image-layout.md
+const (
+	layoutFile    = "oci-layout"
+	indexFile     = "index.json"
+	layoutVersion = "1.0.0"
+
+	// see https://docs.docker.com/reference/cli/docker/image/save/
+	dockerManifestFile = "manifest.json"
+	repositoriesFile   = "repositories"
+
+	refNameAnnotation = "org.opencontainers.image.ref.name"
+	// imageNameAnnotation is the full image name used by containerd and docker when
+	// loading the layout
+	imageNameAnnotation = "io.containerd.image.name"
+)
+
+// ErrNotSingleImage is returned when exporting an index in the docker save format
+var ErrNotSingleImage = errors.New("docker archives hold a single image per tag, select a platform")
+
+type dockerManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// Exporter writes images with all the content they reference into an image layout,
+// optionally also in the format of docker save.
+type Exporter struct {
+	registry string
+	insecure bool
+	name     string
+	w        Writer
+	docker   bool
+
+	written map[string]bool
+	index   []manifest.Descriptor
+	images  []dockerManifest
+	// repositories maps the repository and tag to the top layer as in docker save
+	repositories map[string]map[string]string
+}
+
+func NewExporter(registry string, insecure bool, name string, w Writer, docker bool) *Exporter {
+	return &Exporter{
+		registry:     registry,
+		insecure:     insecure,
+		name:         name,
+		w:            w,
+		docker:       docker,
+		written:      map[string]bool{},
+		repositories: map[string]map[string]string{},
+	}
+}
+
+// Add exports the manifest or index with all the content it references and adds it to
+// the layout index under the tag, if any. The manifest is fetched unless res, its
+// already fetched content, is given.
+func (e *Exporter) Add(ctx context.Context, d manifest.Descriptor, res *manifest.Response, tag string) error {
+	m, err := e.export(ctx, d, res)
+	if err != nil {
+		return err
+	}
+
+	if e.docker && m == nil {
+		return ErrNotSingleImage
+	}
+
+	entry := manifest.Descriptor{
+		MediaType: d.MediaType,
+		Digest:    d.Digest,
+		Size:      d.Size,
+		Platform:  d.Platform,
+	}
+
+	if tag != "" {
+		entry.Annotations = map[string]string{
+			refNameAnnotation:   tag,
+			imageNameAnnotation: image.FormatReference(e.registry, e.name, tag, ""),
+		}
+	}
+
+	e.index = append(e.index, entry)
+
+	if !e.docker {
+		return nil
+	}
+
+	dm := dockerManifest{RepoTags: []string{}}
+	if dm.Config, err = blobPath(m.Config.Digest); err != nil {
+		return err
+	}
+
+	for _, l := range m.Layers {
+		p, err := blobPath(l.Digest)
+		if err != nil {
+			return err
+		}
+		dm.Layers = append(dm.Layers, p)
+	}
+
+	if tag != "" {
+		dm.RepoTags = append(dm.RepoTags, image.FormatReference(e.registry, e.name, tag, ""))
+
+		if len(m.Layers) > 0 {
+			_, hex, _ := strings.Cut(m.Layers[len(m.Layers)-1].Digest, ":")
+			repository := image.FormatReference(e.registry, e.name, "", "")
+			if e.repositories[repository] == nil {
+				e.repositories[repository] = map[string]string{}
+			}
+			e.repositories[repository][tag] = hex
+		}
+	}
+
+	e.images = append(e.images, dm)
+
+	return nil
+}
+
+// export writes the manifest after the content it references, so a manifest present in
+// the layout is always complete. It returns the manifest for images.
+func (e *Exporter) export(ctx context.Context, d manifest.Descriptor, res *manifest.Response) (*manifest.Manifest, error) {
+	var err error
+	if res == nil {
+		if res, err = manifest.GetByDescriptor(ctx, e.registry, e.insecure, e.name, d); err != nil {
+			return nil, fmt.Errorf("getting manifest %s: %w", d.Digest, err)
+		}
+	}
+
+	var m *manifest.Manifest
+	switch {
+	case res.IsSchema1():
+		return nil, errors.New("schema1 images cannot be exported into an image layout")
+	case res.IsIndex():
+		idx, err := res.Index()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range idx.Manifests {
+			if _, err := e.export(ctx, c, nil); err != nil {
+				return nil, err
+			}
+		}
+	case res.IsManifest():
+		if m, err = res.Manifest(); err != nil {
+			return nil, err
+		}
+
+		for _, b := range append([]manifest.Descriptor{m.Config}, m.Layers...) {
+			if err := e.blob(ctx, b); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected content type %q", res.MediaType)
+	}
+
+	if !e.written[d.Digest] && !e.w.HasBlob(d) {
+		if err := e.w.WriteBlob(d, bytes.NewReader(res.Body)); err != nil {
+			return nil, err
+		}
+	}
+	e.written[d.Digest] = true
+
+	return m, nil
+}
+
+func (e *Exporter) blob(ctx context.Context, d manifest.Descriptor) error {
+	if e.written[d.Digest] {
+		return nil
+	}
+
+	foreign := strings.Contains(d.MediaType, "foreign") || strings.Contains(d.MediaType, "nondistributable")
+	if foreign && !e.docker {
+		// non distributable layers are not required to be in the layout
+		log.Logger.Debug("Skipping non distributable layer", zap.String("digest", d.Digest))
+		return nil
+	}
+
+	if e.w.HasBlob(d) {
+		log.Logger.Debug("Reusing blob", zap.String("digest", d.Digest))
+	} else {
+		r, err := blob.Get(ctx, e.registry, e.insecure, e.name, d.Digest, d.Size)
+		if err != nil {
+			if foreign {
+				// docker load requires every layer listed in manifest.json
+				return fmt.Errorf("getting non distributable layer %s required by docker archives: %w", d.Digest, err)
+			}
+
+			return fmt.Errorf("getting blob %s: %w", d.Digest, err)
+		}
+
+		err = e.w.WriteBlob(d, r)
+		_ = r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	e.written[d.Digest] = true
+	return nil
+}
+
+// Close writes the index of the layout, merged with the one already present if any, and
+// closes the writer.
+func (e *Exporter) Close() error {
+	b, err := json.Marshal(map[string]string{"imageLayoutVersion": layoutVersion})
+	if err != nil {
+		return err
+	}
+
+	if err := e.w.WriteFile(layoutFile, b); err != nil {
+		return fmt.Errorf("writing %s: %w", layoutFile, err)
+	}
+
+	idx := manifest.Index{SchemaVersion: 2, MediaType: manifest.OCIIndexV1ContentType}
+	if err := e.readFile(indexFile, &idx); err != nil {
+		return err
+	}
+	idx.Manifests = mergeIndex(idx.Manifests, e.index)
+
+	if err := e.writeJSON(indexFile, idx); err != nil {
+		return err
+	}
+
+	if e.docker {
+		var images []dockerManifest
+		if err := e.readFile(dockerManifestFile, &images); err != nil {
+			return err
+		}
+
+		if err := e.writeJSON(dockerManifestFile, mergeDockerManifests(images, e.images)); err != nil {
+			return err
+		}
+
+		repositories := map[string]map[string]string{}
+		if err := e.readFile(repositoriesFile, &repositories); err != nil {
+			return err
+		}
+
+		for repo, tags := range e.repositories {
+			if repositories[repo] == nil {
+				repositories[repo] = map[string]string{}
+			}
+
+			for tag, id := range tags {
+				repositories[repo][tag] = id
+			}
+		}
+
+		if err := e.writeJSON(repositoriesFile, repositories); err != nil {
+			return err
+		}
+	}
+
+	return e.w.Close()
+}
+
+// readFile decodes a file previously present in the layout, leaving v untouched when missing
+func (e *Exporter) readFile(name string, v any) error {
+	b, err := e.w.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("decoding %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (e *Exporter) writeJSON(name string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if err := e.w.WriteFile(name, b); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// mergeIndex appends the added manifests replacing the existing ones with the same image
+// name or, for untagged ones, the same digest.
+func mergeIndex(existing, added []manifest.Descriptor) []manifest.Descriptor {
+	key := func(d manifest.Descriptor) string {
+		if name := d.Annotations[imageNameAnnotation]; name != "" {
+			return name
+		}
+
+		return d.Digest
+	}
+
+	replaced := map[string]bool{}
+	for _, d := range added {
+		replaced[key(d)] = true
+	}
+
+	merged := make([]manifest.Descriptor, 0, len(existing)+len(added))
+	for _, d := range existing {
+		if !replaced[key(d)] {
+			merged = append(merged, d)
+		}
+	}
+
+	return append(merged, added...)
+}
+
+// mergeDockerManifests appends the added images removing their tags from the existing
+// ones, as a tag can only point to a single image.
+func mergeDockerManifests(existing, added []dockerManifest) []dockerManifest {
+	tags := map[string]bool{}
+	for _, m := range added {
+		for _, t := range m.RepoTags {
+			tags[t] = true
+		}
+	}
+
+	merged := make([]dockerManifest, 0, len(existing)+len(added))
+	for _, m := range existing {
+		repoTags := []string{}
+		for _, t := range m.RepoTags {
+			if !tags[t] {
+				repoTags = append(repoTags, t)
+			}
+		}
+
+		if len(repoTags) == 0 && len(m.RepoTags) > 0 {
+			// the image is only kept when referenced by other tags
+			continue
+		}
+
+		m.RepoTags = repoTags
+		merged = append(merged, m)
+	}
+
+	return append(merged, added...)
+}
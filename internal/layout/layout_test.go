@@ -0,0 +1,313 @@
+package layout
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/content"
+	"github.com/stretchr/testify/require"
+)
+
+type testRegistry struct {
+	manifests map[string][]byte
+	blobs     map[string][]byte
+	// blobRequests counts the blob downloads
+	blobRequests int
+	// manifestRequests counts the manifest downloads
+	manifestRequests int
+}
+
+func (r *testRegistry) blob(b []byte, mediaType string) manifest.Descriptor {
+	d := content.FromBytes(b)
+	r.blobs[d] = b
+	return manifest.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(b))}
+}
+
+func (r *testRegistry) manifest(t *testing.T, v any, mediaType string) manifest.Descriptor {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	d := content.FromBytes(b)
+	r.manifests[d] = b
+	return manifest.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(b))}
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	reference := path.Base(req.URL.Path)
+	if strings.Contains(req.URL.Path, "/blobs/") {
+		r.blobRequests++
+		b, ok := r.blobs[reference]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(b)
+		return
+	}
+
+	r.manifestRequests++
+	b, ok := r.manifests[reference]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var m struct {
+		MediaType string `json:"mediaType"`
+	}
+	_ = json.Unmarshal(b, &m)
+	w.Header().Set("Content-Type", m.MediaType)
+	_, _ = w.Write(b)
+}
+
+func newTestRegistry(t *testing.T) (*testRegistry, manifest.Descriptor, manifest.Descriptor) {
+	r := &testRegistry{manifests: map[string][]byte{}, blobs: map[string][]byte{}}
+
+	base := r.blob([]byte("base layer"), "application/vnd.oci.image.layer.v1.tar+gzip")
+
+	var images []manifest.Descriptor
+	for _, arch := range []string{"amd64", "arm64"} {
+		config := r.blob([]byte(`{"architecture":"`+arch+`","os":"linux"}`), manifest.OCIConfigV1ContentType)
+		top := r.blob([]byte(arch+" layer"), "application/vnd.oci.image.layer.v1.tar+gzip")
+
+		d := r.manifest(t, manifest.Manifest{
+			SchemaVersion: 2,
+			MediaType:     manifest.OCIManifestV1ContentType,
+			Config:        config,
+			Layers:        []manifest.Descriptor{base, top},
+		}, manifest.OCIManifestV1ContentType)
+		d.Platform = &manifest.Platform{Architecture: arch, OS: "linux"}
+		images = append(images, d)
+	}
+
+	idx := r.manifest(t, manifest.Index{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIIndexV1ContentType,
+		Manifests:     images,
+	}, manifest.OCIIndexV1ContentType)
+
+	return r, idx, images[0]
+}
+
+func readIndex(t *testing.T, dir string) manifest.Index {
+	b, err := os.ReadFile(filepath.Join(dir, indexFile))
+	require.NoError(t, err)
+
+	var idx manifest.Index
+	require.NoError(t, json.Unmarshal(b, &idx))
+
+	return idx
+}
+
+func TestExportDirectory(t *testing.T) {
+	r, idx, amd64 := newTestRegistry(t)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	registry := server.URL[len("http://"):]
+	dir := t.TempDir()
+
+	export := func(d manifest.Descriptor, tag string) {
+		w, err := NewDirWriter(dir)
+		require.NoError(t, err)
+
+		e := NewExporter(registry, true, "test/img", w, false)
+		require.NoError(t, e.Add(context.Background(), d, nil, tag))
+		require.NoError(t, e.Close())
+	}
+
+	export(idx, "latest")
+	// the base layer is shared by both images
+	require.Equal(t, 5, r.blobRequests)
+
+	b, err := os.ReadFile(filepath.Join(dir, layoutFile))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"imageLayoutVersion":"1.0.0"}`, string(b))
+
+	for d, expected := range r.blobs {
+		p, err := blobPath(d)
+		require.NoError(t, err)
+
+		actual, err := os.ReadFile(filepath.Join(dir, p))
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	}
+
+	idxPath, err := blobPath(idx.Digest)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, idxPath))
+
+	index := readIndex(t, dir)
+	require.Len(t, index.Manifests, 1)
+	require.Equal(t, idx.Digest, index.Manifests[0].Digest)
+	require.Equal(t, "latest", index.Manifests[0].Annotations[refNameAnnotation])
+	require.Equal(t, registry+"/test/img:latest", index.Manifests[0].Annotations[imageNameAnnotation])
+
+	// exporting again reuses the blobs in the layout
+	r.blobRequests = 0
+	export(amd64, "amd64")
+	require.Equal(t, 0, r.blobRequests)
+
+	index = readIndex(t, dir)
+	require.Len(t, index.Manifests, 2)
+	require.Equal(t, amd64.Digest, index.Manifests[1].Digest)
+	require.Equal(t, "amd64", index.Manifests[1].Platform.Architecture)
+
+	// tags are replaced
+	export(amd64, "latest")
+	index = readIndex(t, dir)
+	require.Len(t, index.Manifests, 2)
+	require.Equal(t, amd64.Digest, index.Manifests[0].Digest)
+	require.Equal(t, amd64.Digest, index.Manifests[1].Digest)
+}
+
+func TestExportDocker(t *testing.T) {
+	r, idx, amd64 := newTestRegistry(t)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	registry := server.URL[len("http://"):]
+
+	var buf bytes.Buffer
+	e := NewExporter(registry, true, "test/img", NewTarWriter(&buf), true)
+	require.ErrorIs(t, e.Add(context.Background(), idx, nil, "latest"), ErrNotSingleImage)
+	require.NoError(t, e.Add(context.Background(), amd64, nil, "latest"))
+	require.NoError(t, e.Close())
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(&buf)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[h.Name] = b
+	}
+
+	var m manifest.Manifest
+	require.NoError(t, json.Unmarshal(r.manifests[amd64.Digest], &m))
+
+	configPath, err := blobPath(m.Config.Digest)
+	require.NoError(t, err)
+	require.Equal(t, r.blobs[m.Config.Digest], files[configPath])
+
+	var images []dockerManifest
+	require.NoError(t, json.Unmarshal(files[dockerManifestFile], &images))
+	require.Len(t, images, 1)
+	require.Equal(t, configPath, images[0].Config)
+	require.Equal(t, []string{registry + "/test/img:latest"}, images[0].RepoTags)
+	require.Len(t, images[0].Layers, 2)
+	for _, l := range images[0].Layers {
+		require.Contains(t, files, l)
+	}
+
+	var repositories map[string]map[string]string
+	require.NoError(t, json.Unmarshal(files[repositoriesFile], &repositories))
+	_, topLayer, _ := strings.Cut(m.Layers[1].Digest, ":")
+	require.Equal(t, map[string]map[string]string{registry + "/test/img": {"latest": topLayer}}, repositories)
+
+	require.Contains(t, files, layoutFile)
+	require.Contains(t, files, indexFile)
+}
+
+func TestExportFetchedManifest(t *testing.T) {
+	r, _, amd64 := newTestRegistry(t)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	res := &manifest.Response{MediaType: amd64.MediaType, Digest: amd64.Digest, Body: r.manifests[amd64.Digest]}
+
+	w, err := NewDirWriter(t.TempDir())
+	require.NoError(t, err)
+
+	e := NewExporter(server.URL[len("http://"):], true, "test/img", w, false)
+	require.NoError(t, e.Add(context.Background(), amd64, res, "latest"))
+	require.NoError(t, e.Close())
+	require.Equal(t, 0, r.manifestRequests)
+}
+
+func TestExportForeignLayers(t *testing.T) {
+	r := &testRegistry{manifests: map[string][]byte{}, blobs: map[string][]byte{}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	registry := server.URL[len("http://"):]
+
+	config := r.blob([]byte(`{"architecture":"amd64","os":"windows"}`), manifest.OCIConfigV1ContentType)
+	foreign := r.blob([]byte("windows base layer"), "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip")
+	d := r.manifest(t, manifest.Manifest{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIManifestV1ContentType,
+		Config:        config,
+		Layers:        []manifest.Descriptor{foreign},
+	}, manifest.OCIManifestV1ContentType)
+
+	foreignPath, err := blobPath(foreign.Digest)
+	require.NoError(t, err)
+
+	export := func(docker bool) (string, error) {
+		dir := t.TempDir()
+		w, err := NewDirWriter(dir)
+		require.NoError(t, err)
+
+		e := NewExporter(registry, true, "test/img", w, docker)
+		if err := e.Add(context.Background(), d, nil, "latest"); err != nil {
+			_ = w.Close()
+			return dir, err
+		}
+
+		return dir, e.Close()
+	}
+
+	t.Run("skipped in image layouts", func(t *testing.T) {
+		dir, err := export(false)
+		require.NoError(t, err)
+		require.NoFileExists(t, filepath.Join(dir, foreignPath))
+	})
+
+	t.Run("fetched for docker", func(t *testing.T) {
+		dir, err := export(true)
+		require.NoError(t, err)
+
+		b, err := os.ReadFile(filepath.Join(dir, foreignPath))
+		require.NoError(t, err)
+		require.Equal(t, "windows base layer", string(b))
+	})
+
+	t.Run("not available for docker", func(t *testing.T) {
+		delete(r.blobs, foreign.Digest)
+
+		_, err := export(true)
+		require.ErrorContains(t, err, "getting non distributable layer "+foreign.Digest+" required by docker archives")
+	})
+}
+
+func TestMergeDockerManifests(t *testing.T) {
+	existing := []dockerManifest{
+		{Config: "a", RepoTags: []string{"img:1", "img:latest"}},
+		{Config: "b", RepoTags: []string{"img:2"}},
+		{Config: "c", RepoTags: []string{}},
+	}
+
+	merged := mergeDockerManifests(existing, []dockerManifest{{Config: "d", RepoTags: []string{"img:latest", "img:2"}}})
+	require.Equal(t, []dockerManifest{
+		{Config: "a", RepoTags: []string{"img:1"}},
+		{Config: "c", RepoTags: []string{}},
+		{Config: "d", RepoTags: []string{"img:latest", "img:2"}},
+	}, merged)
+}
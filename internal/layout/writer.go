@@ -0,0 +1,182 @@
+package layout
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/content"
+)
+
+// Writer writes the files of an image layout
+type Writer interface {
+	// HasBlob returns whether the blob is already present with the expected content
+	HasBlob(d manifest.Descriptor) bool
+	// WriteBlob writes the blob read from r, which is expected to verify the content
+	WriteBlob(d manifest.Descriptor, r io.Reader) error
+	// ReadFile reads a file previously present in the layout, e.g. index.json
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, b []byte) error
+	Close() error
+}
+
+func blobPath(digest string) (string, error) {
+	algorithm, hex, err := content.ParseDigest(digest)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join("blobs", algorithm, hex), nil
+}
+
+type dirWriter struct {
+	dir string
+}
+
+// NewDirWriter returns a writer for the layout in the directory, which is created when
+// missing. Blobs already in the directory are reused.
+func NewDirWriter(dir string) (Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating directory: %w", err)
+	}
+
+	return &dirWriter{dir: dir}, nil
+}
+
+func (w *dirWriter) HasBlob(d manifest.Descriptor) bool {
+	p, err := blobPath(d.Digest)
+	if err != nil {
+		return false
+	}
+
+	f, err := os.Open(filepath.Join(w.dir, filepath.FromSlash(p)))
+	if err != nil {
+		return false
+	}
+	defer f.Close() //nolint
+
+	r, err := content.NewVerifyingReader(f, d.Size, d.Digest)
+	if err != nil {
+		return false
+	}
+
+	_, err = io.Copy(io.Discard, r)
+	return err == nil
+}
+
+func (w *dirWriter) WriteBlob(d manifest.Descriptor, r io.Reader) error {
+	p, err := blobPath(d.Digest)
+	if err != nil {
+		return err
+	}
+
+	return w.write(p, r)
+}
+
+func (w *dirWriter) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(w.dir, name))
+}
+
+func (w *dirWriter) WriteFile(name string, b []byte) error {
+	return w.write(name, bytes.NewReader(b))
+}
+
+// write writes the file atomically so an interrupted export never leaves partial content
+func (w *dirWriter) write(name string, r io.Reader) error {
+	p := filepath.Join(w.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), p)
+}
+
+func (w *dirWriter) Close() error {
+	return nil
+}
+
+type tarWriter struct {
+	tw      *tar.Writer
+	modTime time.Time
+}
+
+// NewTarWriter returns a writer for the layout as a tar archive
+func NewTarWriter(out io.Writer) Writer {
+	return &tarWriter{tw: tar.NewWriter(out), modTime: time.Now()}
+}
+
+func (w *tarWriter) HasBlob(manifest.Descriptor) bool {
+	return false
+}
+
+func (w *tarWriter) WriteBlob(d manifest.Descriptor, r io.Reader) error {
+	p, err := blobPath(d.Digest)
+	if err != nil {
+		return err
+	}
+
+	if err := w.tw.WriteHeader(w.header(p, d.Size)); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w.tw, r); err != nil {
+		return fmt.Errorf("writing %s: %w", p, err)
+	}
+
+	return nil
+}
+
+func (w *tarWriter) ReadFile(name string) ([]byte, error) {
+	return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+}
+
+func (w *tarWriter) WriteFile(name string, b []byte) error {
+	if err := w.tw.WriteHeader(w.header(name, int64(len(b)))); err != nil {
+		return err
+	}
+
+	_, err := w.tw.Write(b)
+	return err
+}
+
+func (w *tarWriter) header(name string, size int64) *tar.Header {
+	return &tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0o644,
+		Size:     size,
+		ModTime:  w.modTime,
+	}
+}
+
+func (w *tarWriter) Close() error {
+	return w.tw.Close()
+}
@@ -0,0 +1,178 @@
+package notation
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+var jwsAlgorithms = map[string]algorithm{
+	"PS256": {name: "PS256", hash: crypto.SHA256, pss: true},
+	"PS384": {name: "PS384", hash: crypto.SHA384, pss: true},
+	"PS512": {name: "PS512", hash: crypto.SHA512, pss: true},
+	"ES256": {name: "ES256", hash: crypto.SHA256},
+	"ES384": {name: "ES384", hash: crypto.SHA384},
+	"ES512": {name: "ES512", hash: crypto.SHA512},
+}
+
+// coseAlgorithms maps the COSE algorithm identifiers to their JWS counterparts
+var coseAlgorithms = map[int64]algorithm{
+	-37: jwsAlgorithms["PS256"],
+	-38: jwsAlgorithms["PS384"],
+	-39: jwsAlgorithms["PS512"],
+	-7:  jwsAlgorithms["ES256"],
+	-35: jwsAlgorithms["ES384"],
+	-36: jwsAlgorithms["ES512"],
+}
+
+// jwsEnvelope is a JWS in JSON serialization as produced by notation
+type jwsEnvelope struct {
+	Payload   string `json:"payload"`
+	Protected string `json:"protected"`
+	Header    struct {
+		X5c [][]byte `json:"x5c"`
+	} `json:"header"`
+	Signature string `json:"signature"`
+}
+
+type jwsProtectedHeader struct {
+	Alg         string    `json:"alg"`
+	Cty         string    `json:"cty"`
+	SigningTime time.Time `json:"io.cncf.notary.signingTime"`
+}
+
+// parseJWS decodes a JWS envelope and returns the signature along with the raw payload
+func parseJWS(b []byte) (*Signature, []byte, error) {
+	var env jwsEnvelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, nil, fmt.Errorf("decoding JWS envelope: %w", err)
+	}
+
+	protected, err := base64.RawURLEncoding.DecodeString(env.Protected)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding protected header: %w", err)
+	}
+
+	var h jwsProtectedHeader
+	if err := json.Unmarshal(protected, &h); err != nil {
+		return nil, nil, fmt.Errorf("decoding protected header: %w", err)
+	}
+
+	alg, ok := jwsAlgorithms[h.Alg]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported signing algorithm %q", h.Alg)
+	}
+
+	if h.Cty != payloadContentType {
+		return nil, nil, fmt.Errorf("unexpected payload content type %q", h.Cty)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding payload: %w", err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(env.Signature)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding signature: %w", err)
+	}
+
+	certs, err := parseCertificates(env.Header.X5c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Signature{
+		SigningTime:  h.SigningTime,
+		Certificates: certs,
+		alg:          alg,
+		signedInput:  []byte(env.Protected + "." + env.Payload),
+		signature:    sig,
+	}, raw, nil
+}
+
+const coseSign1Tag = 18
+
+type coseSign1 struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected struct {
+		X5Chain cbor.RawMessage `cbor:"33,keyasint"`
+	}
+	Payload   []byte
+	Signature []byte
+}
+
+type coseProtectedHeader struct {
+	Alg         int64     `cbor:"1,keyasint"`
+	ContentType string    `cbor:"3,keyasint"`
+	SigningTime time.Time `cbor:"io.cncf.notary.signingTime"`
+}
+
+// parseCOSE decodes a COSE_Sign1 envelope and returns the signature along with the raw
+// payload
+func parseCOSE(b []byte) (*Signature, []byte, error) {
+	var tag cbor.RawTag
+	if err := cbor.Unmarshal(b, &tag); err != nil {
+		return nil, nil, fmt.Errorf("decoding COSE envelope: %w", err)
+	}
+
+	if tag.Number != coseSign1Tag {
+		return nil, nil, fmt.Errorf("unexpected COSE tag %d, expected COSE_Sign1", tag.Number)
+	}
+
+	var env coseSign1
+	if err := cbor.Unmarshal(tag.Content, &env); err != nil {
+		return nil, nil, fmt.Errorf("decoding COSE envelope: %w", err)
+	}
+
+	var h coseProtectedHeader
+	if err := cbor.Unmarshal(env.Protected, &h); err != nil {
+		return nil, nil, fmt.Errorf("decoding protected header: %w", err)
+	}
+
+	alg, ok := coseAlgorithms[h.Alg]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported signing algorithm %d", h.Alg)
+	}
+
+	if h.ContentType != payloadContentType {
+		return nil, nil, fmt.Errorf("unexpected payload content type %q", h.ContentType)
+	}
+
+	// x5chain holds a single certificate or an array of them
+	var ders [][]byte
+	if len(env.Unprotected.X5Chain) == 0 {
+		return nil, nil, errors.New("envelope has no certificate chain")
+	} else if err := cbor.Unmarshal(env.Unprotected.X5Chain, &ders); err != nil {
+		var der []byte
+		if err := cbor.Unmarshal(env.Unprotected.X5Chain, &der); err != nil {
+			return nil, nil, fmt.Errorf("decoding certificate chain: %w", err)
+		}
+
+		ders = [][]byte{der}
+	}
+
+	certs, err := parseCertificates(ders)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signedInput, err := cbor.Marshal([]any{"Signature1", env.Protected, []byte{}, env.Payload})
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding signed input: %w", err)
+	}
+
+	return &Signature{
+		SigningTime:  h.SigningTime,
+		Certificates: certs,
+		alg:          alg,
+		signedInput:  signedInput,
+		signature:    env.Signature,
+	}, env.Payload, nil
+}
@@ -0,0 +1,222 @@
+package notation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/blob"
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/api/referrers"
+)
+
+const (
+	// ArtifactType is the artifact type of notation signatures
+	ArtifactType = "application/vnd.cncf.notary.signature"
+	// JWSMediaType is the media type of signatures in a JWS envelope
+	JWSMediaType = "application/jose+json"
+	// COSEMediaType is the media type of signatures in a COSE envelope
+	COSEMediaType = "application/cose"
+
+	payloadContentType = "application/vnd.cncf.notary.payload.v1+json"
+	signingTimeHeader  = "io.cncf.notary.signingTime"
+)
+
+// ErrInvalidSignature is returned when a signature does not verify against its certificate
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signature is a notation signature decoded from its envelope
+type Signature struct {
+	// Digest is the digest of the signature manifest
+	Digest string
+	// MediaType is the media type of the envelope
+	MediaType   string
+	SigningTime time.Time
+	// Certificates is the certificate chain starting with the signing certificate
+	Certificates []*x509.Certificate
+	// Target is the descriptor of the signed manifest
+	Target manifest.Descriptor
+
+	alg         algorithm
+	signedInput []byte
+	signature   []byte
+}
+
+// Subject returns the subject of the signing certificate
+func (s Signature) Subject() string {
+	if len(s.Certificates) == 0 {
+		return ""
+	}
+
+	return s.Certificates[0].Subject.String()
+}
+
+type payload struct {
+	TargetArtifact manifest.Descriptor `json:"targetArtifact"`
+}
+
+// algorithm is one of the signing algorithms allowed by the notary project, that is
+// RSASSA-PSS and ECDSA with SHA-256, SHA-384 or SHA-512
+type algorithm struct {
+	name string
+	hash crypto.Hash
+	pss  bool
+}
+
+// Find finds the notation signatures of the manifest with the given digest in its referrers
+func Find(ctx context.Context, registry string, insecure bool, name, digest string) ([]Signature, error) {
+	descriptors, err := referrers.List(ctx, registry, insecure, name, digest, ArtifactType)
+	if err != nil {
+		return nil, fmt.Errorf("listing referrers: %w", err)
+	}
+
+	var signatures []Signature
+	for _, d := range descriptors {
+		res, err := manifest.GetByDescriptor(ctx, registry, insecure, name, d)
+		if err != nil {
+			return nil, fmt.Errorf("getting signature manifest: %w", err)
+		}
+
+		m, err := res.Manifest()
+		if err != nil {
+			return nil, fmt.Errorf("getting signature manifest: %w", err)
+		}
+
+		for _, l := range m.Layers {
+			if l.MediaType != JWSMediaType && l.MediaType != COSEMediaType {
+				continue
+			}
+
+			r, err := blob.Get(ctx, registry, insecure, name, l.Digest, l.Size)
+			if err != nil {
+				return nil, fmt.Errorf("getting signature envelope: %w", err)
+			}
+
+			b, err := io.ReadAll(r)
+			_ = r.Close()
+			if err != nil {
+				return nil, fmt.Errorf("reading signature envelope: %w", err)
+			}
+
+			s, err := Parse(l.MediaType, b)
+			if err != nil {
+				return nil, fmt.Errorf("parsing signature %s: %w", d.Digest, err)
+			}
+
+			s.Digest = d.Digest
+			signatures = append(signatures, *s)
+		}
+	}
+
+	return signatures, nil
+}
+
+// Parse decodes a JWS or COSE signature envelope
+func Parse(mediaType string, b []byte) (*Signature, error) {
+	var (
+		s   *Signature
+		raw []byte
+		err error
+	)
+
+	switch mediaType {
+	case JWSMediaType:
+		s, raw, err = parseJWS(b)
+	case COSEMediaType:
+		s, raw, err = parseCOSE(b)
+	default:
+		return nil, fmt.Errorf("unsupported envelope media type %q", mediaType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.Certificates) == 0 {
+		return nil, errors.New("envelope has no certificate chain")
+	}
+
+	var p payload
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("decoding payload: %w", err)
+	}
+
+	s.MediaType = mediaType
+	s.Target = p.TargetArtifact
+
+	return s, nil
+}
+
+// Verify checks the certificate chain of the signature leads to one of the trusted roots
+// at signing time, the signature is valid for the signing certificate and the signed
+// manifest is the one with the given digest.
+func (s Signature) Verify(roots *x509.CertPool, digest string) error {
+	intermediates := x509.NewCertPool()
+	for _, c := range s.Certificates[1:] {
+		intermediates.AddCert(c)
+	}
+
+	if _, err := s.Certificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   s.SigningTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return fmt.Errorf("verifying certificate chain: %w", err)
+	}
+
+	h := s.alg.hash.New()
+	h.Write(s.signedInput)
+	hashed := h.Sum(nil)
+
+	switch pub := s.Certificates[0].PublicKey.(type) {
+	case *rsa.PublicKey:
+		if !s.alg.pss {
+			return fmt.Errorf("algorithm %s does not match the RSA certificate", s.alg.name)
+		}
+
+		if err := rsa.VerifyPSS(pub, s.alg.hash, hashed, s.signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}); err != nil {
+			return ErrInvalidSignature
+		}
+	case *ecdsa.PublicKey:
+		if s.alg.pss {
+			return fmt.Errorf("algorithm %s does not match the ECDSA certificate", s.alg.name)
+		}
+
+		// both JWS and COSE encode ECDSA signatures as the concatenation of r and s
+		n := len(s.signature) / 2
+		r, ss := new(big.Int).SetBytes(s.signature[:n]), new(big.Int).SetBytes(s.signature[n:])
+		if len(s.signature)%2 != 0 || !ecdsa.Verify(pub, hashed, r, ss) {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("unsupported certificate key type %T", pub)
+	}
+
+	if s.Target.Digest != digest {
+		return fmt.Errorf("signature refers to %s rather than %s", s.Target.Digest, digest)
+	}
+
+	return nil
+}
+
+func parseCertificates(ders [][]byte) ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0, len(ders))
+	for _, der := range ders {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate: %w", err)
+		}
+
+		certs = append(certs, c)
+	}
+
+	return certs, nil
+}
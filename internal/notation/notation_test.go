@@ -0,0 +1,262 @@
+package notation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const imageDigest = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+var signingTime = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newCertificate(t *testing.T, cn string, key crypto.Signer, parent *testCA) *x509.Certificate {
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"Example"}},
+		NotBefore:    signingTime.Add(-time.Hour),
+		NotAfter:     signingTime.Add(time.Hour),
+	}
+
+	signer, issuer := key, tmpl
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+		signer, issuer = parent.key, parent.cert
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, key.Public(), signer)
+	require.NoError(t, err)
+
+	c, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return c
+}
+
+func rawECDSASignature(t *testing.T, key *ecdsa.PrivateKey, hashed []byte) []byte {
+	r, s, err := ecdsa.Sign(rand.Reader, key, hashed)
+	require.NoError(t, err)
+
+	size := (key.Curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	s.FillBytes(sig[size:])
+
+	return sig
+}
+
+func sign(t *testing.T, key crypto.Signer, alg algorithm, input []byte) []byte {
+	h := alg.hash.New()
+	h.Write(input)
+	hashed := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return rawECDSASignature(t, k, hashed)
+	case *rsa.PrivateKey:
+		sig, err := rsa.SignPSS(rand.Reader, k, alg.hash, hashed, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		require.NoError(t, err)
+		return sig
+	}
+
+	t.Fatalf("unexpected key type %T", key)
+	return nil
+}
+
+func targetPayload(digest string) []byte {
+	return []byte(fmt.Sprintf(`{"targetArtifact":{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":%q,"size":100}}`, digest))
+}
+
+func jwsEnvelopeFor(t *testing.T, key crypto.Signer, alg string, chain []*x509.Certificate, payload []byte) []byte {
+	protected, err := json.Marshal(map[string]any{
+		"alg":             alg,
+		"cty":             payloadContentType,
+		"crit":            []string{"io.cncf.notary.signingScheme"},
+		signingTimeHeader: signingTime.Format(time.RFC3339),
+	})
+	require.NoError(t, err)
+
+	env := jwsEnvelope{
+		Protected: base64.RawURLEncoding.EncodeToString(protected),
+		Payload:   base64.RawURLEncoding.EncodeToString(payload),
+	}
+	for _, c := range chain {
+		env.Header.X5c = append(env.Header.X5c, c.Raw)
+	}
+	env.Signature = base64.RawURLEncoding.EncodeToString(sign(t, key, jwsAlgorithms[alg], []byte(env.Protected+"."+env.Payload)))
+
+	b, err := json.Marshal(env)
+	require.NoError(t, err)
+
+	return b
+}
+
+func coseEnvelopeFor(t *testing.T, key crypto.Signer, alg int64, chain []*x509.Certificate, payload []byte) []byte {
+	protected, err := cbor.Marshal(map[any]any{
+		1:                 alg,
+		3:                 payloadContentType,
+		signingTimeHeader: cbor.Tag{Number: 1, Content: signingTime.Unix()},
+	})
+	require.NoError(t, err)
+
+	input, err := cbor.Marshal([]any{"Signature1", protected, []byte{}, payload})
+	require.NoError(t, err)
+
+	ders := make([][]byte, 0, len(chain))
+	for _, c := range chain {
+		ders = append(ders, c.Raw)
+	}
+
+	b, err := cbor.Marshal(cbor.Tag{Number: coseSign1Tag, Content: []any{
+		protected,
+		map[any]any{33: ders},
+		payload,
+		sign(t, key, coseAlgorithms[alg], input),
+	}})
+	require.NoError(t, err)
+
+	return b
+}
+
+func TestParseAndVerify(t *testing.T) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	root := &testCA{key: rootKey}
+	root.cert = newCertificate(t, "Example Root", rootKey, nil)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leaf := newCertificate(t, "Example Signer", leafKey, root)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaLeaf := newCertificate(t, "Example RSA Signer", rsaKey, root)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherRoot := newCertificate(t, "Other Root", otherKey, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(otherRoot)
+
+	tests := []struct {
+		name        string
+		mediaType   string
+		envelope    []byte
+		roots       *x509.CertPool
+		expectedErr bool
+	}{
+		{
+			name:      "JWS with ECDSA",
+			mediaType: JWSMediaType,
+			envelope:  jwsEnvelopeFor(t, leafKey, "ES256", []*x509.Certificate{leaf, root.cert}, targetPayload(imageDigest)),
+			roots:     roots,
+		},
+		{
+			name:      "JWS with RSASSA-PSS",
+			mediaType: JWSMediaType,
+			envelope:  jwsEnvelopeFor(t, rsaKey, "PS384", []*x509.Certificate{rsaLeaf}, targetPayload(imageDigest)),
+			roots:     roots,
+		},
+		{
+			name:      "COSE with ECDSA",
+			mediaType: COSEMediaType,
+			envelope:  coseEnvelopeFor(t, leafKey, -7, []*x509.Certificate{leaf, root.cert}, targetPayload(imageDigest)),
+			roots:     roots,
+		},
+		{
+			name:      "COSE with RSASSA-PSS",
+			mediaType: COSEMediaType,
+			envelope:  coseEnvelopeFor(t, rsaKey, -37, []*x509.Certificate{rsaLeaf}, targetPayload(imageDigest)),
+			roots:     roots,
+		},
+		{
+			name:        "untrusted root",
+			mediaType:   JWSMediaType,
+			envelope:    jwsEnvelopeFor(t, leafKey, "ES256", []*x509.Certificate{leaf}, targetPayload(imageDigest)),
+			roots:       otherRoots,
+			expectedErr: true,
+		},
+		{
+			name:        "signed by another key",
+			mediaType:   COSEMediaType,
+			envelope:    coseEnvelopeFor(t, otherKey, -7, []*x509.Certificate{leaf}, targetPayload(imageDigest)),
+			roots:       roots,
+			expectedErr: true,
+		},
+		{
+			name:        "signature for another image",
+			mediaType:   JWSMediaType,
+			envelope:    jwsEnvelopeFor(t, leafKey, "ES256", []*x509.Certificate{leaf}, targetPayload("sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")),
+			roots:       roots,
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.mediaType, tt.envelope)
+			require.NoError(t, err)
+			require.True(t, signingTime.Equal(s.SigningTime))
+			require.Contains(t, s.Subject(), "Signer")
+
+			err = s.Verify(tt.roots, imageDigest)
+			if tt.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, imageDigest, s.Target.Digest)
+			}
+		})
+	}
+}
+
+func TestLoadTrustStore(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	root := newCertificate(t, "Example Root", key, nil)
+
+	dir := t.TempDir()
+	_, err = LoadTrustStore(dir)
+	require.Error(t, err)
+
+	caDir := filepath.Join(dir, "x509", "ca", "example")
+	require.NoError(t, os.MkdirAll(caDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(caDir, "root.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw}), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0o644))
+
+	pool, err := LoadTrustStore(dir)
+	require.NoError(t, err)
+
+	_, err = root.Verify(x509.VerifyOptions{Roots: pool, CurrentTime: signingTime})
+	require.NoError(t, err)
+}
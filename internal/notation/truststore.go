@@ -0,0 +1,77 @@
+package notation
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadTrustStore loads the PEM or DER encoded X.509 certificates found in the directory
+// and its subdirectories, which covers both a flat directory and the notation trust
+// store layout (e.g. x509/ca/<name>/*.crt).
+func LoadTrustStore(dir string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	count := 0
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".pem", ".crt", ".cer", ".der":
+		default:
+			return nil
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		certs, err := parseCertificateFile(b)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", path, err)
+		}
+
+		for _, c := range certs {
+			pool.AddCert(c)
+			count++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading trust store: %w", err)
+	}
+
+	if count == 0 {
+		return nil, fmt.Errorf("no certificates found in trust store %s", dir)
+	}
+
+	return pool, nil
+}
+
+func parseCertificateFile(b []byte) ([]*x509.Certificate, error) {
+	block, rest := pem.Decode(b)
+	if block == nil {
+		return x509.ParseCertificates(b)
+	}
+
+	var ders [][]byte
+	for ; block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			ders = append(ders, block.Bytes)
+		}
+	}
+
+	return parseCertificates(ders)
+}
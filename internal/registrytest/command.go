@@ -0,0 +1,87 @@
+package registrytest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Execute runs the command with the arguments and returns what it writes to stdout.
+// Commands are package singletons so the flags of the command and its subcommands are
+// reset to the defaults first, and usage and errors are silenced as the root command
+// does.
+func Execute(t testing.TB, cmd *cobra.Command, args ...string) (string, error) {
+	t.Helper()
+
+	var visit func(c *cobra.Command)
+	visit = func(c *cobra.Command) {
+		c.Flags().VisitAll(func(f *pflag.Flag) { resetFlag(t, f) })
+		c.PersistentFlags().VisitAll(func(f *pflag.Flag) { resetFlag(t, f) })
+		for _, sub := range c.Commands() {
+			visit(sub)
+		}
+	}
+	visit(cmd)
+
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs(args)
+	defer cmd.SetArgs(nil)
+
+	_, err := cmd.ExecuteContextC(context.Background())
+	return stdout.String(), err
+}
+
+// resetFlag restores the default value of the flag and marks it as not changed. Slice
+// and map values append to what a previous run set once they were set, so they are
+// replaced by fresh values holding the default, which are read through the getters of
+// the flag set.
+func resetFlag(t testing.TB, f *pflag.Flag) {
+	t.Helper()
+
+	f.Changed = false
+
+	var def []string
+	if s := strings.TrimSuffix(strings.TrimPrefix(f.DefValue, "["), "]"); s != "" {
+		var err error
+		if def, err = csv.NewReader(strings.NewReader(s)).Read(); err != nil {
+			t.Fatalf("parsing default of flag %q: %v", f.Name, err)
+		}
+	}
+
+	fs := pflag.NewFlagSet(f.Name, pflag.ContinueOnError)
+	switch f.Value.Type() {
+	case "stringSlice":
+		fs.StringSlice(f.Name, def, f.Usage)
+	case "stringArray":
+		fs.StringArray(f.Name, def, f.Usage)
+	case "stringToString":
+		m := make(map[string]string, len(def))
+		for _, kv := range def {
+			k, v, _ := strings.Cut(kv, "=")
+			m[k] = v
+		}
+		fs.StringToString(f.Name, m, f.Usage)
+	default:
+		if _, ok := f.Value.(pflag.SliceValue); ok || strings.HasPrefix(f.Value.Type(), "stringTo") {
+			t.Fatalf("resetting flag %q of type %s is not supported", f.Name, f.Value.Type())
+		}
+
+		if err := f.Value.Set(f.DefValue); err != nil {
+			t.Fatalf("resetting flag %q: %v", f.Name, err)
+		}
+		return
+	}
+
+	f.Value = fs.Lookup(f.Name).Value
+}
@@ -0,0 +1,37 @@
+package registrytest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteResetsFlags(t *testing.T) {
+	cmd := &cobra.Command{
+		Use: "test",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			name, _ := cmd.Flags().GetString("name")
+			labels, _ := cmd.Flags().GetStringToString("label")
+			order, _ := cmd.Flags().GetStringSlice("order")
+			cmd.Printf("%s %v %v %t", name, labels, order, cmd.Flags().Changed("order"))
+			return nil
+		},
+	}
+	cmd.Flags().String("name", "default", "")
+	cmd.Flags().StringToString("label", map[string]string{"a": "1"}, "")
+	cmd.Flags().StringSlice("order", []string{"x", "y"}, "")
+
+	out, err := Execute(t, cmd, "--name", "other", "--label", "b=2", "--order", "z")
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprint("other ", map[string]string{"b": "2"}, " [z] true"), out)
+
+	out, err = Execute(t, cmd, "--label", "c=3", "--order", "w")
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprint("default ", map[string]string{"c": "3"}, " [w] true"), out)
+
+	out, err = Execute(t, cmd)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprint("default ", map[string]string{"a": "1"}, " [x y] false"), out)
+}
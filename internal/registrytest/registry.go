@@ -0,0 +1,258 @@
+// Package registrytest provides an in-memory registry to test against
+package registrytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jcchavezs/nuro/internal/api/manifest"
+	"github.com/jcchavezs/nuro/internal/content"
+)
+
+var (
+	uploadPath  = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/(.*)$`)
+	contentPath = regexp.MustCompile(`^/v2/(.+)/(manifests|blobs|referrers)/([^/]+)$`)
+)
+
+// Registry is a registry storing blobs and manifests in memory. Repositories are not
+// isolated from each other, every name shares the same content and tags.
+type Registry struct {
+	server *httptest.Server
+
+	// Token is the bearer token required by every request when set
+	Token string
+	// Referrers enables the referrers API, otherwise clients fall back to the tag schema
+	Referrers bool
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	types     map[string]string
+	tags      map[string]string
+	requests  map[string]int
+}
+
+// New starts a registry that is closed when the test finishes
+func New(t testing.TB) *Registry {
+	r := &Registry{
+		blobs:     map[string][]byte{},
+		manifests: map[string][]byte{},
+		types:     map[string]string{},
+		tags:      map[string]string{},
+		requests:  map[string]int{},
+	}
+	r.server = httptest.NewServer(r)
+	t.Cleanup(r.server.Close)
+
+	return r
+}
+
+// Host returns the host of the registry to be used in image references
+func (r *Registry) Host() string {
+	return r.server.Listener.Addr().String()
+}
+
+// PutBlob stores the blob and returns its descriptor
+func (r *Registry) PutBlob(b []byte, mediaType string) manifest.Descriptor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := content.FromBytes(b)
+	r.blobs[d] = b
+
+	return manifest.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(b))}
+}
+
+// PutManifest stores the manifest, an index or image manifest encoded as JSON, under
+// the tags and returns its descriptor. The media type is taken from the manifest.
+func (r *Registry) PutManifest(t testing.TB, v any, tags ...string) manifest.Descriptor {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("encoding manifest: %v", err)
+	}
+
+	var m struct {
+		MediaType string `json:"mediaType"`
+	}
+	_ = json.Unmarshal(b, &m)
+
+	return r.PutRawManifest(b, m.MediaType, tags...)
+}
+
+// PutRawManifest stores the manifest as is under the tags and returns its descriptor
+func (r *Registry) PutRawManifest(b []byte, mediaType string, tags ...string) manifest.Descriptor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := content.FromBytes(b)
+	r.manifests[d] = b
+	r.types[d] = mediaType
+	for _, tag := range tags {
+		r.tags[tag] = d
+	}
+
+	return manifest.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(b))}
+}
+
+// Manifest returns the manifest stored under the reference, either a tag or a digest
+func (r *Registry) Manifest(reference string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.manifests[r.resolve(reference)]
+	return b, ok
+}
+
+// Requests returns how many requests were made with the method to the path, e.g.
+// GET /v2/app/blobs/sha256:...
+func (r *Registry) Requests(method, path string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.requests[method+" "+path]
+}
+
+func (r *Registry) resolve(reference string) string {
+	if d, ok := r.tags[reference]; ok {
+		return d
+	}
+
+	return reference
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests[req.Method+" "+req.URL.Path]++
+
+	if r.Token != "" && req.Header.Get("Authorization") != "Bearer "+r.Token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if m := uploadPath.FindStringSubmatch(req.URL.Path); m != nil {
+		switch req.Method {
+		case http.MethodPost:
+			w.Header().Set("Location", "/v2/"+m[1]+"/blobs/uploads/session")
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodPut:
+			b, _ := io.ReadAll(req.Body)
+			r.blobs[req.URL.Query().Get("digest")] = b
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	m := contentPath.FindStringSubmatch(req.URL.Path)
+	if m == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch m[2] {
+	case "blobs":
+		b, ok := r.blobs[m[3]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Docker-Content-Digest", m[3])
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(b))
+	case "manifests":
+		if req.Method == http.MethodPut {
+			b, _ := io.ReadAll(req.Body)
+			d := content.FromBytes(b)
+			r.manifests[d] = b
+			r.types[d] = req.Header.Get("Content-Type")
+			if !content.IsDigest(m[3]) {
+				r.tags[m[3]] = d
+			}
+
+			var subject struct {
+				Subject *manifest.Descriptor `json:"subject"`
+			}
+			if _ = json.Unmarshal(b, &subject); r.Referrers && subject.Subject != nil {
+				w.Header().Set("OCI-Subject", subject.Subject.Digest)
+			}
+
+			w.Header().Set("Docker-Content-Digest", d)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		d := r.resolve(m[3])
+		b, ok := r.manifests[d]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", r.types[d])
+		w.Header().Set("Docker-Content-Digest", d)
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(b))
+	case "referrers":
+		if !r.Referrers {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", manifest.OCIIndexV1ContentType)
+		_ = json.NewEncoder(w).Encode(manifest.Index{
+			SchemaVersion: 2,
+			MediaType:     manifest.OCIIndexV1ContentType,
+			Manifests:     r.referrers(m[3]),
+		})
+	}
+}
+
+// referrers returns the descriptors of the manifests whose subject is the digest
+func (r *Registry) referrers(digest string) []manifest.Descriptor {
+	descriptors := []manifest.Descriptor{}
+	for d, b := range r.manifests {
+		var m manifest.Manifest
+		if err := json.Unmarshal(b, &m); err != nil || m.Subject == nil || m.Subject.Digest != digest {
+			continue
+		}
+
+		descriptors = append(descriptors, manifest.Descriptor{
+			MediaType:    r.types[d],
+			Digest:       d,
+			Size:         int64(len(b)),
+			ArtifactType: m.Type(),
+			Annotations:  m.Annotations,
+		})
+	}
+
+	sort.Slice(descriptors, func(i, j int) bool { return descriptors[i].Digest < descriptors[j].Digest })
+
+	return descriptors
+}
+
+// PutImage stores an OCI image manifest with the config and gzip layers under the tags
+// and returns its descriptor
+func (r *Registry) PutImage(t testing.TB, config []byte, layers [][]byte, tags ...string) manifest.Descriptor {
+	m := manifest.Manifest{
+		SchemaVersion: 2,
+		MediaType:     manifest.OCIManifestV1ContentType,
+		Config:        r.PutBlob(config, manifest.OCIConfigV1ContentType),
+		Layers:        []manifest.Descriptor{},
+	}
+
+	for _, l := range layers {
+		m.Layers = append(m.Layers, r.PutBlob(l, "application/vnd.oci.image.layer.v1.tar+gzip"))
+	}
+
+	return r.PutManifest(t, m, tags...)
+}